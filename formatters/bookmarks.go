@@ -0,0 +1,204 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/valueof/meh/schema"
+)
+
+// bookmarkFolder is a named group of links: Medium bookmarks, a list
+// or clapped posts.
+type bookmarkFolder struct {
+	Name        string
+	Description string
+	Posts       []schema.Post
+}
+
+// BookmarksFormatter moves reading lists out of Medium. It writes Medium
+// bookmarks, every list and, optionally, clapped posts as folders into
+// files that browsers and read-it-later services can import:
+//
+//   - bookmarks.html in the Netscape bookmark file format (browsers)
+//   - pocket.csv (Pocket)
+//   - instapaper.csv (Instapaper)
+//   - raindrop.csv (Raindrop.io)
+//
+// Bookmark and clap timestamps are carried over, lists don't have them.
+// All export data is also passed along to JSONFormatter.
+//
+// BookmarksFormatter writes all files on Close.
+type BookmarksFormatter struct {
+	jsonFallback
+	logger    *log.Logger
+	root      string
+	withClaps bool
+	bookmarks []schema.Post
+	lists     []schema.List
+	claps     []schema.Post
+}
+
+func NewBookmarksFormatter(root string, withClaps bool, logger *log.Logger) *BookmarksFormatter {
+	return &BookmarksFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		withClaps:    withClaps,
+	}
+}
+
+func (w *BookmarksFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Bookmarks:
+		w.bookmarks = v.Posts
+	case schema.Lists:
+		w.lists = v.Lists
+	case schema.Claps:
+		w.claps = []schema.Post{}
+		for _, c := range v.Claps {
+			w.claps = append(w.claps, c.Post)
+		}
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close writes bookmarks.html, pocket.csv, instapaper.csv and raindrop.csv
+func (w *BookmarksFormatter) Close() error {
+	folders := []bookmarkFolder{}
+
+	if len(w.bookmarks) > 0 {
+		folders = append(folders, bookmarkFolder{Name: "Medium bookmarks", Posts: w.bookmarks})
+	}
+
+	for _, l := range w.lists {
+		folders = append(folders, bookmarkFolder{Name: l.Name, Description: l.Summary, Posts: l.Posts})
+	}
+
+	if w.withClaps && len(w.claps) > 0 {
+		folders = append(folders, bookmarkFolder{Name: "Medium claps", Posts: w.claps})
+	}
+
+	err := w.write("bookmarks.html", []byte(netscapeBookmarks(folders)))
+	if err != nil {
+		return err
+	}
+
+	pocket := [][]string{{"title", "url", "time_added", "tags", "status"}}
+	instapaper := [][]string{{"URL", "Title", "Selection", "Folder", "Timestamp"}}
+	raindrop := [][]string{{"url", "folder", "title", "note", "tags", "created"}}
+
+	for _, f := range folders {
+		for _, p := range f.Posts {
+			if p.Url == "" {
+				continue
+			}
+
+			unix, iso := "", ""
+			if t, ok := archiveTime(p.PublishedAt); ok {
+				unix = strconv.FormatInt(t.Unix(), 10)
+				iso = t.Format(time.RFC3339)
+			}
+
+			pocket = append(pocket, []string{p.Title, p.Url, unix, f.Name, "unread"})
+			instapaper = append(instapaper, []string{p.Url, p.Title, "", f.Name, unix})
+			raindrop = append(raindrop, []string{p.Url, f.Name, p.Title, "", "medium", iso})
+		}
+	}
+
+	for name, rows := range map[string][][]string{
+		"pocket.csv":     pocket,
+		"instapaper.csv": instapaper,
+		"raindrop.csv":   raindrop,
+	} {
+		var b bytes.Buffer
+		c := csv.NewWriter(&b)
+		c.WriteAll(rows)
+		if err := c.Error(); err != nil {
+			w.logger.Printf("can't convert bookmarks into %s: %v", name, err)
+			return err
+		}
+
+		err := w.write(name, b.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *BookmarksFormatter) write(name string, dat []byte) error {
+	err := writeOutput(w.root, name, dat)
+	if err != nil {
+		w.logger.Printf("can't write %s: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// netscapeBookmarks renders folders in the Netscape bookmark file format
+// that all major browsers and bookmark managers can import.
+func netscapeBookmarks(folders []bookmarkFolder) string {
+	var b bytes.Buffer
+
+	b.WriteString(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`)
+
+	for _, f := range folders {
+		fmt.Fprintf(&b, "    <DT><H3>%s</H3>\n", html.EscapeString(f.Name))
+		if f.Description != "" {
+			fmt.Fprintf(&b, "    <DD>%s\n", html.EscapeString(f.Description))
+		}
+
+		b.WriteString("    <DL><p>\n")
+		for _, p := range f.Posts {
+			if p.Url == "" {
+				continue
+			}
+
+			title := p.Title
+			if title == "" {
+				title = p.Url
+			}
+
+			date := ""
+			if t, ok := archiveTime(p.PublishedAt); ok {
+				date = fmt.Sprintf(" ADD_DATE=\"%d\"", t.Unix())
+			}
+
+			fmt.Fprintf(&b, "        <DT><A HREF=\"%s\"%s>%s</A>\n", html.EscapeString(p.Url), date, html.EscapeString(title))
+		}
+		b.WriteString("    </DL><p>\n")
+	}
+
+	b.WriteString("</DL><p>\n")
+	return b.String()
+}
+
+// archiveTime parses timestamps found in the archive. Posts have RFC 3339
+// timestamps while bookmarks and claps look like "2020-08-21 4:50 pm".
+// The latter don't have a timezone so they are treated as UTC.
+func archiveTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+
+	if t, err := time.Parse("2006-01-2 3:04 pm", s); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
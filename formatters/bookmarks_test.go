@@ -0,0 +1,73 @@
+package formatters_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestBookmarksFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewBookmarksFormatter(root, false, log.New(io.Discard, "", 0))
+
+	w.WriteFile("bookmarks", schema.Bookmarks{Posts: []schema.Post{
+		{Url: "https://medium.com/p/e06382acd276", Title: "Sprint Burndown Charts", PublishedAt: "2020-08-21 4:50 pm"},
+	}})
+	w.WriteFile("lists", schema.Lists{Lists: []schema.List{
+		{Name: "Owls & Co", Posts: []schema.Post{{Url: "https://medium.com/p/97c9489ccafa", Title: "Owls, explained"}}},
+	}})
+	w.WriteFile("claps", schema.Claps{Claps: []schema.Clap{
+		{Post: schema.Post{Url: "https://medium.com/p/9e53ca408c48", Title: "Welcome to Medium"}, Amount: 1},
+	}})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	tests := map[string][]string{
+		"bookmarks.html": {
+			"<DT><H3>Medium bookmarks</H3>",
+			`<DT><A HREF="https://medium.com/p/e06382acd276" ADD_DATE="1598028600">Sprint Burndown Charts</A>`,
+			"<DT><H3>Owls &amp; Co</H3>",
+			`<DT><A HREF="https://medium.com/p/97c9489ccafa">Owls, explained</A>`,
+		},
+		"pocket.csv": {
+			"title,url,time_added,tags,status\n",
+			"Sprint Burndown Charts,https://medium.com/p/e06382acd276,1598028600,Medium bookmarks,unread\n",
+			"\"Owls, explained\",https://medium.com/p/97c9489ccafa,,Owls & Co,unread\n",
+		},
+		"instapaper.csv": {
+			"URL,Title,Selection,Folder,Timestamp\n",
+			"https://medium.com/p/e06382acd276,Sprint Burndown Charts,,Medium bookmarks,1598028600\n",
+		},
+		"raindrop.csv": {
+			"url,folder,title,note,tags,created\n",
+			"https://medium.com/p/e06382acd276,Medium bookmarks,Sprint Burndown Charts,,medium,2020-08-21T16:50:00Z\n",
+		},
+	}
+
+	for name, want := range tests {
+		dat, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Errorf("can't read %s: %v", name, err)
+			continue
+		}
+
+		for _, s := range want {
+			if !strings.Contains(string(dat), s) {
+				t.Errorf("%s doesn't contain %s", name, s)
+			}
+		}
+
+		if strings.Contains(string(dat), "Welcome to Medium") {
+			t.Errorf("%s contains claps", name)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/valueof/meh/schema"
+)
+
+// CSVFormatter flattens list-shaped export data (claps, bookmarks, IPs,
+// sessions, follows, interests, lists, membership charges and so on) into
+// one table per dataset with stable column headers. Posts are summarised
+// into a single posts table without their content.
+//
+// CSVFormatter writes the posts table on Close.
+type CSVFormatter struct {
+	logger *log.Logger
+	root   string
+	comma  rune
+	ext    string
+	posts  [][]string
+}
+
+// NewCSVFormatter returns a formatter that writes comma-separated .csv files
+func NewCSVFormatter(root string, logger *log.Logger) *CSVFormatter {
+	return &CSVFormatter{
+		logger: logger,
+		root:   root,
+		comma:  ',',
+		ext:    ".csv",
+		posts:  [][]string{},
+	}
+}
+
+// NewTSVFormatter returns a formatter that writes tab-separated .tsv files
+func NewTSVFormatter(root string, logger *log.Logger) *CSVFormatter {
+	w := NewCSVFormatter(root, logger)
+	w.comma = '\t'
+	w.ext = ".tsv"
+	return w
+}
+
+var csvPostHeader = []string{"id", "url", "title", "subtitle", "author_username", "status", "kind", "published_at", "file", "words", "summary"}
+
+func (w *CSVFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Post:
+		text := postText(v)
+		author := ""
+		if v.Author != nil {
+			author = v.Author.Username
+		}
+		w.posts = append(w.posts, []string{
+			v.Id, v.Url, v.Title, v.Subtitle, author, string(v.Status), string(v.Kind), v.PublishedAt, filepath.ToSlash(fp),
+			strconv.Itoa(len(strings.Fields(text))), summarize(text, 280),
+		})
+		return nil
+	case schema.PostIndex:
+		rows := [][]string{}
+		for _, p := range v.Posts {
+			replyTo := ""
+			if p.InReplyTo != nil {
+				replyTo = p.InReplyTo.Url
+			}
+			rows = append(rows, []string{p.Name, p.Title, p.PublishedAt, p.Url, replyTo})
+		}
+		return w.write(fp, []string{"name", "title", "published_at", "url", "in_reply_to"}, rows)
+	case schema.BlockedUsers:
+		rows := [][]string{}
+		for _, u := range v.Users {
+			rows = append(rows, []string{u.Username, u.Name, u.Url})
+		}
+		return w.write(fp, []string{"username", "name", "url"}, rows)
+	case schema.Bookmarks:
+		rows := [][]string{}
+		for _, p := range v.Posts {
+			rows = append(rows, []string{p.Id, p.Url, p.Title, p.PublishedAt})
+		}
+		return w.write(fp, []string{"post_id", "url", "title", "published_at"}, rows)
+	case schema.Claps:
+		rows := [][]string{}
+		for _, c := range v.Claps {
+			rows = append(rows, []string{c.Post.Id, c.Post.Url, c.Post.Title, c.Post.PublishedAt, strconv.Itoa(c.Amount)})
+		}
+		return w.write(fp, []string{"post_id", "url", "title", "published_at", "amount"}, rows)
+	case schema.Highlights:
+		rows := [][]string{}
+		for _, h := range v.Highlights {
+			text := []string{}
+			for _, g := range h.Body {
+				text = append(text, grafText(g))
+			}
+			rows = append(rows, []string{h.CreatedAt, strings.Join(text, "\n\n")})
+		}
+		return w.write(fp, []string{"created_at", "text"}, rows)
+	case schema.Interests:
+		rows := [][]string{}
+		for _, p := range v.Publications {
+			rows = append(rows, []string{"publication", p.Name, p.Url})
+		}
+		for _, t := range v.Tags {
+			rows = append(rows, []string{"tag", t.Name, t.Url})
+		}
+		for _, t := range v.Topics {
+			rows = append(rows, []string{"topic", t.Name, t.Url})
+		}
+		for _, u := range v.Writers {
+			rows = append(rows, []string{"writer", u.Name, u.Url})
+		}
+		return w.write(fp, []string{"kind", "name", "url"}, rows)
+	case schema.IPs:
+		rows := [][]string{}
+		for _, ip := range v.IPs {
+			rows = append(rows, []string{ip.Address, ip.CreatedAt})
+		}
+		return w.write(fp, []string{"address", "created_at"}, rows)
+	case schema.Lists:
+		rows := [][]string{}
+		for _, l := range v.Lists {
+			for _, p := range l.Posts {
+				rows = append(rows, []string{l.Name, l.Summary, p.Id, p.Url, p.Title})
+			}
+		}
+		return w.write(fp, []string{"list", "summary", "post_id", "url", "title"}, rows)
+	case schema.Publications:
+		rows := [][]string{}
+		for _, p := range v.Publications {
+			rows = append(rows, []string{p.Name, p.Url})
+		}
+		return w.write(fp, []string{"name", "url"}, rows)
+	case schema.Topics:
+		rows := [][]string{}
+		for _, t := range v.Topics {
+			rows = append(rows, []string{t.Name, t.Url})
+		}
+		return w.write(fp, []string{"name", "url"}, rows)
+	case schema.Users:
+		rows := [][]string{}
+		for _, u := range v.Users {
+			rows = append(rows, []string{u.Username, u.Name, u.Url})
+		}
+		return w.write(fp, []string{"username", "name", "url"}, rows)
+	case schema.Sessions:
+		rows := [][]string{}
+		for _, s := range v.Sessions {
+			rows = append(rows, []string{s.CreatedAt, s.LastSeenAt, s.LastSeenLocation, s.UserAgent})
+		}
+		return w.write(fp, []string{"created_at", "last_seen_at", "last_seen_location", "user_agent"}, rows)
+	case schema.Profile:
+		if v.User != nil {
+			u := v.User
+			err := w.write(fp, []string{"user_id", "username", "name", "email", "url", "created_at", "bio"}, [][]string{
+				{u.Id, u.Username, u.Name, v.Email, u.Url, u.CreatedAt, u.Bio},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		rows := [][]string{}
+		for _, m := range v.Memberships {
+			rows = append(rows, []string{m.Id, m.StartedAt, m.EndedAt, formatAmount(m.Amount), m.Type})
+		}
+		err := w.write("memberships", []string{"id", "started_at", "ended_at", "amount", "type"}, rows)
+		if err != nil {
+			return err
+		}
+
+		rows = [][]string{}
+		for _, c := range v.MembershipCharges {
+			rows = append(rows, []string{c.CreatedAt, formatAmount(c.Amount)})
+		}
+		return w.write("membership_charges", []string{"created_at", "amount"}, rows)
+	}
+
+	w.logger.Printf("%s can't be converted into a table, skipping", fp)
+	return nil
+}
+
+// Close writes the posts table
+func (w *CSVFormatter) Close() error {
+	if len(w.posts) == 0 {
+		return nil
+	}
+	return w.write("posts", csvPostHeader, w.posts)
+}
+
+func (w *CSVFormatter) write(fp string, header []string, rows [][]string) error {
+	var b bytes.Buffer
+	c := csv.NewWriter(&b)
+	c.Comma = w.comma
+
+	c.Write(header)
+	c.WriteAll(rows)
+	if err := c.Error(); err != nil {
+		w.logger.Printf("can't convert %s into a table: %v", fp, err)
+		return err
+	}
+
+	err := writeOutput(w.root, fp+w.ext, b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write %s%s: %v", fp, w.ext, err)
+		return err
+	}
+
+	return nil
+}
+
+// postText returns plain text of all grafs in a post
+func postText(post schema.Post) string {
+	text := []string{}
+	forEachGraf(post, func(g schema.Graf) {
+		if t := grafText(g); t != "" {
+			text = append(text, t)
+		}
+	})
+	return strings.Join(text, "\n\n")
+}
+
+// grafText returns graf text with line breaks put back in. List items go
+// on separate lines.
+func grafText(g schema.Graf) string {
+	if len(g.Items) > 0 {
+		items := []string{}
+		for _, item := range g.Items {
+			items = append(items, grafText(item))
+		}
+		return strings.Join(items, "\n")
+	}
+
+	var b strings.Builder
+	for _, t := range tokenize(g.Text, g.Markups) {
+		switch t.kind {
+		case tokenText:
+			b.WriteString(t.text)
+		case tokenBreak:
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// summarize collapses whitespace in s and cuts it down to max runes
+func summarize(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+
+	return strings.TrimSpace(string(r[:max-1])) + "…"
+}
+
+func formatAmount(f float64) string {
+	return fmt.Sprintf("%.2f", f)
+}
@@ -0,0 +1,71 @@
+package formatters_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestCSVFormatter(t *testing.T) {
+	tests := map[string]struct {
+		v    any
+		want string
+	}{
+		"claps": {
+			v: schema.Claps{Claps: []schema.Clap{
+				{Amount: 25, Post: schema.Post{Id: "b8d43e4c204d", Title: "Re-thinking J-school, again"}},
+			}},
+			want: "post_id,url,title,published_at,amount\nb8d43e4c204d,,\"Re-thinking J-school, again\",,25\n",
+		},
+		"following/users": {
+			v:    schema.Users{Users: []schema.User{{Username: "anton", Name: "Anton", Url: "https://medium.com/@anton"}}},
+			want: "username,name,url\nanton,Anton,https://medium.com/@anton\n",
+		},
+		"membership_charges": {
+			v: schema.Profile{MembershipCharges: []schema.MembershipCharge{
+				{CreatedAt: "2022-01-01", Amount: 5},
+			}},
+			want: "created_at,amount\n2022-01-01,5.00\n",
+		},
+		"posts": {
+			v: schema.Post{
+				Id:     "70c5683f3778",
+				Title:  "oh, right",
+				Author: &schema.User{Username: "anton"},
+				Status: schema.DRAFT,
+				Kind:   schema.STORY,
+				Content: []schema.Section{{Body: []schema.InnerSection{{Body: []schema.Graf{
+					{Type: schema.P, Text: "onetwo", Markups: []schema.Markup{{Type: schema.BR, Start: 3, End: 3}}},
+				}}}}},
+			},
+			want: "id,url,title,subtitle,author_username,status,kind,published_at,file,words,summary\n70c5683f3778,,\"oh, right\",,anton,draft,story,,posts,2,one two\n",
+		},
+	}
+
+	for fp, tt := range tests {
+		root := t.TempDir()
+		w := formatters.NewCSVFormatter(root, log.New(io.Discard, "", 0))
+
+		w.WriteFile(fp, tt.v)
+		err := formatters.Close(w)
+		if err != nil {
+			t.Errorf("%s: %v", fp, err)
+			continue
+		}
+
+		have, err := os.ReadFile(filepath.Join(root, fp+".csv"))
+		if err != nil {
+			t.Errorf("%s: %v", fp, err)
+			continue
+		}
+
+		if string(have) != tt.want {
+			t.Errorf("%s\nwant: %q\nhave: %q", fp, tt.want, have)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package formatters
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+var epubTemplates = template.Must(template.New("epub").Funcs(template.FuncMap{
+	"esc": html.EscapeString,
+}).Parse(`
+{{define "container"}}<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{end}}
+
+{{define "opf"}}<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="en">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">{{esc .Id}}</dc:identifier>
+    <dc:title>{{esc .Title}}</dc:title>
+    {{if .Author}}<dc:creator>{{esc .Author}}</dc:creator>
+    {{end}}{{if .Description}}<dc:description>{{esc .Description}}</dc:description>
+    {{end}}<dc:language>en</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    {{if .Cover}}<meta name="cover" content="{{.Cover.Id}}"/>
+    {{end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="titlepage" href="title.xhtml" media-type="application/xhtml+xml"/>
+    {{range .Chapters}}<item id="{{.Id}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+    {{end}}{{range .Images}}<item id="{{.Id}}" href="{{.Href}}" media-type="{{.MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+    {{end}}
+  </manifest>
+  <spine>
+    <itemref idref="titlepage"/>
+    {{range .Chapters}}<itemref idref="{{.Id}}"/>
+    {{end}}
+  </spine>
+</package>
+{{end}}
+
+{{define "nav"}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+<title>{{esc .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>Contents</h1>
+<ol>
+{{range .Chapters}}<li><a href="{{.Href}}">{{esc .Title}}</a></li>
+{{end}}</ol>
+</nav>
+</body>
+</html>
+{{end}}
+
+{{define "title"}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+<title>{{esc .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="titlepage" class="titlepage">
+{{if .Cover}}<img src="{{.Cover.Href}}" alt="{{esc .Author}}"/>
+{{end}}<h1>{{esc .Title}}</h1>
+{{if .Author}}<p class="author">{{esc .Author}}</p>
+{{end}}{{if .Description}}<p class="description">{{esc .Description}}</p>
+{{end}}</section>
+</body>
+</html>
+{{end}}
+
+{{define "chapter"}}<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+<title>{{esc .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<article epub:type="chapter">
+<h1>{{esc .Title}}</h1>
+{{if .Date}}<p class="date">{{esc .Date}}</p>
+{{end}}{{.Body}}</article>
+</body>
+</html>
+{{end}}
+
+{{define "style"}}body { font-family: Georgia, Cambria, "Times New Roman", Times, serif; line-height: 1.5; }
+img { max-width: 100%; }
+figure { margin: 1em 0; text-align: center; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 3px solid #ccc; font-style: italic; }
+.date, .author { color: #666; }
+.titlepage { text-align: center; margin-top: 20%; }
+.titlepage img { max-width: 40%; border-radius: 50%; }
+{{end}}
+`))
+
+type epubChapter struct {
+	Id    string
+	Href  string
+	Title string
+	Date  string
+	Body  string
+}
+
+type epubImage struct {
+	Id         string
+	Href       string
+	MediaType  string
+	Properties string
+	src        string
+}
+
+type epubBook struct {
+	Id          string
+	Title       string
+	Author      string
+	Description string
+	Modified    string
+	Cover       *epubImage
+	Chapters    []epubChapter
+	Images      []*epubImage
+}
+
+// EPUBFormatter builds an EPUB 3 book out of all posts, ordered by their
+// publication date. Author name and the title page come from the user
+// profile. Images are embedded only if they were downloaded into the
+// images/ directory by Parser.FetchImages, since e-books can't reference
+// remote images. All other export data is passed along to JSONFormatter.
+//
+// EPUBFormatter writes book.epub on Close.
+type EPUBFormatter struct {
+	jsonFallback
+	logger  *log.Logger
+	root    string
+	posts   []schema.Post
+	profile *schema.Profile
+}
+
+func NewEPUBFormatter(root string, logger *log.Logger) *EPUBFormatter {
+	return &EPUBFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		posts:        []schema.Post{},
+	}
+}
+
+func (w *EPUBFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Post:
+		// Responses are mostly short comments on other posts and drafts
+		// were never published, neither belongs in a book
+		if v.Kind != schema.RESPONSE && v.Status != schema.DRAFT {
+			w.posts = append(w.posts, v)
+		}
+		return nil
+	case schema.Profile:
+		w.profile = &v
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close assembles the book and writes it into book.epub. Posts without
+// a publication date go last.
+func (w *EPUBFormatter) Close() error {
+	if len(w.posts) == 0 {
+		w.logger.Printf("no posts found, not writing book.epub")
+		return nil
+	}
+
+	sort.SliceStable(w.posts, func(i, j int) bool {
+		a, b := w.posts[i].PublishedAt, w.posts[j].PublishedAt
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+
+	book := epubBook{
+		Title:    "Medium stories",
+		Modified: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Chapters: []epubChapter{},
+		Images:   []*epubImage{},
+	}
+
+	images := map[string]*epubImage{}
+	embed := func(img *schema.Image) *epubImage {
+		if img == nil || !util.ValidImageName(img.Name) {
+			return nil
+		}
+
+		if e, ok := images[img.Name]; ok {
+			return e
+		}
+
+		src := filepath.Join(w.root, "images", img.Name)
+		head := make([]byte, 512)
+		f, err := os.Open(src)
+		if err != nil {
+			w.logger.Printf("image %s wasn't downloaded, leaving it out of the book", img.Name)
+			images[img.Name] = nil
+			return nil
+		}
+		n, _ := f.Read(head)
+		f.Close()
+
+		mediaType := http.DetectContentType(head[:n])
+		if !strings.HasPrefix(mediaType, "image/") {
+			w.logger.Printf("%s is not an image, leaving it out of the book", img.Name)
+			images[img.Name] = nil
+			return nil
+		}
+
+		ext := path.Ext(img.Name)
+		if ext == "" {
+			ext = "." + strings.TrimPrefix(mediaType, "image/")
+		}
+
+		e := &epubImage{
+			Id:        fmt.Sprintf("img%d", len(book.Images)+1),
+			Href:      fmt.Sprintf("images/img%d%s", len(book.Images)+1, ext),
+			MediaType: mediaType,
+			src:       src,
+		}
+		images[img.Name] = e
+		book.Images = append(book.Images, e)
+		return e
+	}
+
+	if w.profile != nil && w.profile.User != nil {
+		user := w.profile.User
+		book.Author = user.Name
+		if book.Author == "" {
+			book.Author = user.Username
+		}
+		if book.Author != "" {
+			book.Title = "Stories by " + book.Author
+		}
+		book.Description = user.Bio
+
+		if book.Cover = embed(user.ProfilePic); book.Cover != nil {
+			book.Cover.Properties = "cover-image"
+		}
+	}
+
+	ids := sha256.New()
+	for i, post := range w.posts {
+		ids.Write([]byte(post.Id))
+
+		body := htmlPost(stripTitle(post), func(img *schema.Image) string {
+			if e := embed(img); e != nil {
+				return e.Href
+			}
+			return ""
+		})
+
+		book.Chapters = append(book.Chapters, epubChapter{
+			Id:    fmt.Sprintf("chapter%d", i+1),
+			Href:  fmt.Sprintf("chapter%d.xhtml", i+1),
+			Title: post.Title,
+			Date:  displayDate(post.PublishedAt),
+			Body:  body,
+		})
+	}
+	book.Id = fmt.Sprintf("urn:meh:%x", ids.Sum(nil)[:16])
+
+	var b bytes.Buffer
+	err := w.build(&b, book)
+	if err != nil {
+		w.logger.Printf("can't build book.epub: %v", err)
+		return err
+	}
+
+	err = writeOutput(w.root, "book.epub", b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write book.epub: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *EPUBFormatter) build(out *bytes.Buffer, book epubBook) error {
+	z := zip.NewWriter(out)
+
+	// The mimetype file must come first and must not be compressed.
+	mt, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	mt.Write([]byte("application/epub+zip"))
+
+	files := []struct {
+		name string
+		tmpl string
+		data any
+	}{
+		{"META-INF/container.xml", "container", nil},
+		{"OEBPS/content.opf", "opf", book},
+		{"OEBPS/nav.xhtml", "nav", book},
+		{"OEBPS/title.xhtml", "title", book},
+		{"OEBPS/style.css", "style", nil},
+	}
+
+	for _, ch := range book.Chapters {
+		files = append(files, struct {
+			name string
+			tmpl string
+			data any
+		}{"OEBPS/" + ch.Href, "chapter", ch})
+	}
+
+	for _, f := range files {
+		fw, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+
+		err = epubTemplates.ExecuteTemplate(fw, f.tmpl, f.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, img := range book.Images {
+		dat, err := os.ReadFile(img.src)
+		if err != nil {
+			return err
+		}
+
+		fw, err := z.Create("OEBPS/" + img.Href)
+		if err != nil {
+			return err
+		}
+
+		_, err = fw.Write(dat)
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
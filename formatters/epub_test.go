@@ -0,0 +1,99 @@
+package formatters_test
+
+import (
+	"archive/zip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestEPUBFormatter(t *testing.T) {
+	root := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	os.MkdirAll(filepath.Join(root, "images"), os.ModePerm)
+	os.WriteFile(filepath.Join(root, "images", "owl.png"), png, 0644)
+	os.WriteFile(filepath.Join(root, "secret.png"), png, 0644)
+
+	w := formatters.NewEPUBFormatter(root, log.New(io.Discard, "", 0))
+	w.WriteFile("posts/second", schema.Post{
+		Title:       "Second",
+		PublishedAt: "2022-04-05T10:00:00.000Z",
+		Content: []schema.Section{
+			{Body: []schema.InnerSection{{Body: []schema.Graf{
+				{Type: schema.IMG, Image: &schema.Image{Name: "owl.png"}},
+				{Type: schema.IMG, Image: &schema.Image{Name: "missing.png"}},
+				{Type: schema.IMG, Image: &schema.Image{Name: "../secret.png"}},
+			}}}},
+		},
+	})
+	w.WriteFile("posts/first", schema.Post{Title: "First", PublishedAt: "2015-02-05T02:56:45.739Z"})
+	w.WriteFile("posts/reply", schema.Post{Title: "Nice!", Kind: schema.RESPONSE, PublishedAt: "2016-01-01T10:00:00.000Z"})
+	w.WriteFile("posts/draft", schema.Post{Title: "Untitled", Status: schema.DRAFT})
+	w.WriteFile("profile", schema.Profile{User: &schema.User{Name: "Anton Kovalyov"}})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	z, err := zip.OpenReader(filepath.Join(root, "book.epub"))
+	if err != nil {
+		t.Fatalf("can't open book.epub: %v", err)
+	}
+	defer z.Close()
+
+	if z.File[0].Name != "mimetype" || z.File[0].Method != zip.Store {
+		t.Errorf("mimetype must be the first uncompressed file in the archive")
+	}
+
+	read := func(name string) string {
+		f, err := z.Open(name)
+		if err != nil {
+			t.Errorf("book.epub doesn't have %s", name)
+			return ""
+		}
+		defer f.Close()
+		dat, _ := io.ReadAll(f)
+		return string(dat)
+	}
+
+	opf := read("OEBPS/content.opf")
+	for _, want := range []string{
+		`<dc:creator>Anton Kovalyov</dc:creator>`,
+		`<dc:title>Stories by Anton Kovalyov</dc:title>`,
+		`<item id="img1" href="images/img1.png" media-type="image/png"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("content.opf doesn't contain %s", want)
+		}
+	}
+
+	if !strings.Contains(read("OEBPS/chapter1.xhtml"), "<h1>First</h1>") {
+		t.Errorf("chapters are not ordered by publication date")
+	}
+
+	chapter := read("OEBPS/chapter2.xhtml")
+	if !strings.Contains(chapter, `<img src="images/img1.png"`) || strings.Contains(chapter, "missing.png") {
+		t.Errorf("chapter2.xhtml has wrong images:\n%s", chapter)
+	}
+
+	for _, f := range z.File {
+		if f.Name == "OEBPS/chapter3.xhtml" {
+			t.Errorf("responses and drafts shouldn't be in the book")
+		}
+
+		if f.Name == "OEBPS/images/img2.png" {
+			t.Errorf("images outside of images/ shouldn't be in the book")
+		}
+	}
+
+	if read("OEBPS/images/img1.png") != string(png) {
+		t.Errorf("images/img1.png wasn't embedded")
+	}
+}
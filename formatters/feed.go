@@ -0,0 +1,314 @@
+package formatters
+
+import (
+	"bytes"
+	"html"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+var atomTemplate = template.Must(template.New("atom").Funcs(template.FuncMap{
+	"esc": html.EscapeString,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>{{esc .Id}}</id>
+  <title>{{esc .Title}}</title>
+  <updated>{{.Updated}}</updated>
+  {{if .Link}}<link rel="alternate" type="text/html" href="{{esc .Link}}"/>
+  {{end}}{{if .Self}}<link rel="self" type="application/atom+xml" href="{{esc .Self}}"/>
+  {{end}}{{with .Author}}<author>
+    <name>{{esc .Name}}</name>
+    {{if .Url}}<uri>{{esc .Url}}</uri>
+    {{end}}{{if .Email}}<email>{{esc .Email}}</email>
+    {{end}}</author>
+  {{end}}<generator>meh</generator>
+  {{range .Entries}}<entry>
+    <id>{{esc .Id}}</id>
+    <title>{{esc .Title}}</title>
+    <link rel="alternate" type="text/html" href="{{esc .Link}}"/>
+    <published>{{.Published.Format "2006-01-02T15:04:05Z07:00"}}</published>
+    <updated>{{.Published.Format "2006-01-02T15:04:05Z07:00"}}</updated>
+    <content type="html">{{esc .Content}}</content>
+  </entry>
+  {{end}}
+</feed>
+`))
+
+var rssTemplate = template.Must(template.New("rss").Funcs(template.FuncMap{
+	"esc": html.EscapeString,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <title>{{esc .Title}}</title>
+  <link>{{esc .Link}}</link>
+  <description>{{esc .Title}}</description>
+  {{if .Self}}<atom:link rel="self" type="application/rss+xml" href="{{esc .Self}}"/>
+  {{end}}<lastBuildDate>{{.Updated}}</lastBuildDate>
+  <generator>meh</generator>
+  {{range .Entries}}<item>
+    <title>{{esc .Title}}</title>
+    <link>{{esc .Link}}</link>
+    <guid isPermaLink="{{.Permalink}}">{{esc .Id}}</guid>
+    <pubDate>{{.Published.Format "Mon, 02 Jan 2006 15:04:05 -0700"}}</pubDate>
+    {{with $.Author}}<dc:creator>{{esc .Name}}</dc:creator>
+    {{end}}<description>{{esc .Content}}</description>
+  </item>
+  {{end}}
+</channel>
+</rss>
+`))
+
+type feedAuthor struct {
+	Name  string
+	Url   string
+	Email string
+}
+
+type feedEntry struct {
+	Id        string
+	Permalink bool
+	Title     string
+	Link      string
+	Published time.Time
+	Content   string
+}
+
+type feed struct {
+	Id      string
+	Title   string
+	Link    string
+	Self    string
+	Updated string
+	Author  *feedAuthor
+	Entries []feedEntry
+}
+
+// FeedFormatter builds Atom 1.0 and RSS 2.0 feeds out of all published
+// posts, newest first. Every entry carries full post content and uses the
+// canonical Medium URL as its ID. Author details come from the user
+// profile. Drafts are left out since they don't have a publication date.
+//
+// If baseURL is set, post links are rewritten to <baseURL>/<slug>, both
+// in the feed itself and in links between posts, so the feed can be
+// served from a new domain. All other export data is passed along to
+// JSONFormatter.
+//
+// FeedFormatter writes atom.xml and rss.xml on Close.
+type FeedFormatter struct {
+	jsonFallback
+	logger  *log.Logger
+	root    string
+	baseURL string
+	posts   []schema.Post
+	names   []string
+	profile *schema.Profile
+}
+
+func NewFeedFormatter(root, baseURL string, logger *log.Logger) *FeedFormatter {
+	return &FeedFormatter{
+		logger:       logger,
+		root:         root,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		jsonFallback: newJSONFallback(root, logger),
+		posts:        []schema.Post{},
+		names:        []string{},
+	}
+}
+
+func (w *FeedFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Post:
+		// Responses are mostly short comments on other posts, they don't
+		// belong in a feed
+		if v.Kind != schema.RESPONSE {
+			w.posts = append(w.posts, v)
+			w.names = append(w.names, filepath.Base(fp))
+		}
+		return nil
+	case schema.Profile:
+		w.profile = &v
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close writes atom.xml and rss.xml
+func (w *FeedFormatter) Close() error {
+	f := feed{
+		Title:   "Medium stories",
+		Link:    "https://medium.com",
+		Entries: []feedEntry{},
+	}
+
+	if w.profile != nil && w.profile.User != nil {
+		user := w.profile.User
+		author := &feedAuthor{
+			Name:  user.Name,
+			Url:   user.Url,
+			Email: w.profile.Email,
+		}
+
+		if author.Name == "" {
+			author.Name = user.Username
+		}
+
+		if author.Name != "" {
+			f.Title = "Stories by " + author.Name
+			f.Author = author
+		}
+
+		if user.Url != "" {
+			f.Link = user.Url
+		}
+	}
+
+	// New links for every post, keyed by the original Medium URL
+	links := map[string]string{}
+	if w.baseURL != "" {
+		f.Link = w.baseURL + "/"
+		for i, post := range w.posts {
+			slug := util.ParseMediumSlug(post.Url)
+			if slug == "" {
+				slug = slugify(w.names[i])
+			}
+			if post.Url != "" {
+				links[post.Url] = w.baseURL + "/" + slug
+			}
+		}
+	}
+
+	for _, post := range w.posts {
+		t, err := time.Parse(time.RFC3339, post.PublishedAt)
+		if err != nil {
+			continue
+		}
+
+		entry := feedEntry{
+			Id:        post.Url,
+			Permalink: true,
+			Title:     post.Title,
+			Link:      post.Url,
+			Published: t.UTC(),
+			Content:   htmlPost(stripTitle(rewriteLinks(post, links)), imageSource),
+		}
+
+		if link, ok := links[post.Url]; ok {
+			entry.Link = link
+		}
+
+		if entry.Id == "" {
+			entry.Id = "urn:meh:" + post.Id
+			entry.Permalink = false
+		}
+
+		f.Entries = append(f.Entries, entry)
+	}
+
+	sort.SliceStable(f.Entries, func(i, j int) bool {
+		return f.Entries[i].Published.After(f.Entries[j].Published)
+	})
+
+	updated := time.Now().UTC()
+	if len(f.Entries) > 0 {
+		updated = f.Entries[0].Published
+	}
+
+	f.Id = f.Link
+	if w.baseURL != "" {
+		f.Self = w.baseURL + "/atom.xml"
+	}
+
+	f.Updated = updated.Format(time.RFC3339)
+	err := w.write("atom.xml", atomTemplate, f)
+	if err != nil {
+		return err
+	}
+
+	if w.baseURL != "" {
+		f.Self = w.baseURL + "/rss.xml"
+	}
+
+	f.Updated = updated.Format(time.RFC1123Z)
+	return w.write("rss.xml", rssTemplate, f)
+}
+
+func (w *FeedFormatter) write(name string, t *template.Template, f feed) error {
+	var b bytes.Buffer
+	err := t.Execute(&b, f)
+	if err != nil {
+		w.logger.Printf("can't render %s: %v", name, err)
+		return err
+	}
+
+	err = writeOutput(w.root, name, b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write %s: %v", name, err)
+		return err
+	}
+
+	return nil
+}
+
+// rewriteLinks returns a copy of post where links that point to other
+// posts are replaced according to links. The original post is left as is.
+func rewriteLinks(post schema.Post, links map[string]string) schema.Post {
+	if len(links) == 0 {
+		return post
+	}
+
+	sections := make([]schema.Section, len(post.Content))
+	for i, s := range post.Content {
+		inners := make([]schema.InnerSection, len(s.Body))
+		for j, inner := range s.Body {
+			grafs := make([]schema.Graf, len(inner.Body))
+			for k, g := range inner.Body {
+				grafs[k] = rewriteGrafLinks(g, links)
+			}
+			inner.Body = grafs
+			inners[j] = inner
+		}
+		s.Body = inners
+		sections[i] = s
+	}
+
+	post.Content = sections
+	return post
+}
+
+// rewriteGrafLinks returns a copy of g with links replaced according to
+// links, including links inside list items and link cards.
+func rewriteGrafLinks(g schema.Graf, links map[string]string) schema.Graf {
+	markups := make([]schema.Markup, len(g.Markups))
+	for i, m := range g.Markups {
+		if link, ok := links[m.Href]; ok && m.Type == schema.A {
+			m.Href = link
+		}
+		markups[i] = m
+	}
+	g.Markups = markups
+
+	if g.Embed != nil {
+		if link, ok := links[g.Embed.Source]; ok {
+			embed := *g.Embed
+			embed.Source = link
+			g.Embed = &embed
+		}
+	}
+
+	if g.Items != nil {
+		items := make([]schema.Graf, len(g.Items))
+		for i, item := range g.Items {
+			items[i] = rewriteGrafLinks(item, links)
+		}
+		g.Items = items
+	}
+
+	return g
+}
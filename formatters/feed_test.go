@@ -0,0 +1,149 @@
+package formatters_test
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestFeedFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewFeedFormatter(root, "https://example.com/posts/", log.New(io.Discard, "", 0))
+
+	card := &schema.Embed{
+		Provider: schema.LINK,
+		Source:   "https://medium.com/@anton/oh-right-70c5683f3778",
+		Title:    "oh, right",
+	}
+
+	w.WriteFile("posts/old", schema.Post{
+		Url:         "https://medium.com/@anton/oh-right-70c5683f3778",
+		Title:       "oh, right",
+		PublishedAt: "2015-02-05T02:56:45.739Z",
+	})
+	w.WriteFile("posts/new", schema.Post{
+		Url:         "https://medium.com/@anton/about-owls-1234567890ab",
+		Title:       "About owls",
+		PublishedAt: "2016-03-01T10:00:00.000Z",
+		Content: []schema.Section{
+			{Body: []schema.InnerSection{{Body: []schema.Graf{
+				{Type: schema.P, Text: "see my old poem", Markups: []schema.Markup{
+					{Type: schema.A, Start: 7, End: 15, Href: "https://medium.com/@anton/oh-right-70c5683f3778"},
+				}},
+				{Type: schema.EMBED, Embed: card},
+				{Type: schema.UL, Items: []schema.Graf{
+					{Type: schema.LI, Text: "still true", Markups: []schema.Markup{
+						{Type: schema.A, Start: 6, End: 10, Href: "https://medium.com/@anton/oh-right-70c5683f3778"},
+					}},
+				}},
+			}}}},
+		},
+	})
+	w.WriteFile("posts/draft", schema.Post{Title: "Draft"})
+	w.WriteFile("posts/reply", schema.Post{
+		Url:         "https://medium.com/@anton/nice-e0b4bd5f2c1a",
+		Title:       "Nice!",
+		Kind:        schema.RESPONSE,
+		PublishedAt: "2017-01-01T10:00:00.000Z",
+	})
+	w.WriteFile("profile", schema.Profile{
+		Email: "anton@example.com",
+		User:  &schema.User{Name: "Anton Kovalyov", Url: "https://medium.com/@anton"},
+	})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	dat, err := os.ReadFile(filepath.Join(root, "atom.xml"))
+	if err != nil {
+		t.Fatalf("can't read atom.xml: %v", err)
+	}
+
+	var atom struct {
+		Title  string `xml:"title"`
+		Author string `xml:"author>name"`
+		Items  []struct {
+			Id    string `xml:"id"`
+			Title string `xml:"title"`
+			Link  struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+
+	err = xml.Unmarshal(dat, &atom)
+	if err != nil {
+		t.Fatalf("atom.xml is not valid XML: %v", err)
+	}
+
+	if atom.Title != "Stories by Anton Kovalyov" || atom.Author != "Anton Kovalyov" {
+		t.Errorf("feed doesn't use the profile: %s by %s", atom.Title, atom.Author)
+	}
+
+	if len(atom.Items) != 2 {
+		t.Fatalf("want 2 entries; have %d", len(atom.Items))
+	}
+
+	entry := atom.Items[0]
+	if entry.Title != "About owls" {
+		t.Errorf("want newest post first; have %s", entry.Title)
+	}
+
+	if entry.Id != "https://medium.com/@anton/about-owls-1234567890ab" {
+		t.Errorf("want canonical URL as ID; have %s", entry.Id)
+	}
+
+	if entry.Link.Href != "https://example.com/posts/about-owls" {
+		t.Errorf("want link on the new domain; have %s", entry.Link.Href)
+	}
+
+	want := `see my <a href="https://example.com/posts/oh-right">old poem</a>`
+	if !strings.Contains(entry.Content, want) {
+		t.Errorf("want content to contain %s; have %s", want, entry.Content)
+	}
+
+	want = `still <a href="https://example.com/posts/oh-right">true</a>`
+	if !strings.Contains(entry.Content, want) {
+		t.Errorf("want list items to contain %s; have %s", want, entry.Content)
+	}
+
+	want = `<a href="https://example.com/posts/oh-right">oh, right</a>`
+	if !strings.Contains(entry.Content, want) {
+		t.Errorf("want link cards to contain %s; have %s", want, entry.Content)
+	}
+
+	if card.Source != "https://medium.com/@anton/oh-right-70c5683f3778" {
+		t.Errorf("want original embed to be left as is; have %s", card.Source)
+	}
+
+	dat, err = os.ReadFile(filepath.Join(root, "rss.xml"))
+	if err != nil {
+		t.Fatalf("can't read rss.xml: %v", err)
+	}
+
+	var rss struct {
+		Items []struct {
+			Guid    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}
+
+	err = xml.Unmarshal(dat, &rss)
+	if err != nil {
+		t.Fatalf("rss.xml is not valid XML: %v", err)
+	}
+
+	if len(rss.Items) != 2 || rss.Items[1].PubDate != "Thu, 05 Feb 2015 02:56:45 +0000" {
+		t.Errorf("unexpected RSS items: %+v", rss.Items)
+	}
+}
@@ -0,0 +1,80 @@
+package formatters
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// outputs keeps track of files written into every output root during a
+// run, so the manifest lists only them and not whatever else lives there.
+// The server runs several conversions at once, each into its own root.
+var outputs = struct {
+	sync.Mutex
+	paths map[string]map[string]bool
+}{paths: map[string]map[string]bool{}}
+
+// RecordOutput remembers that a file fp relative to root was written
+// during this run. Formatters call it for every file they write, anything
+// else that writes into the output directory (e.g. images) should too.
+func RecordOutput(root, fp string) {
+	root = filepath.Clean(root)
+
+	outputs.Lock()
+	defer outputs.Unlock()
+
+	if outputs.paths[root] == nil {
+		outputs.paths[root] = map[string]bool{}
+	}
+	outputs.paths[root][filepath.ToSlash(filepath.Clean(fp))] = true
+}
+
+// takeOutputs returns sorted paths of files recorded for root and forgets
+// about them.
+func takeOutputs(root string) []string {
+	root = filepath.Clean(root)
+
+	outputs.Lock()
+	defer outputs.Unlock()
+
+	paths := []string{}
+	for fp := range outputs.paths[root] {
+		paths = append(paths, fp)
+	}
+	delete(outputs.paths, root)
+
+	sort.Strings(paths)
+	return paths
+}
+
+// ForgetOutputs drops files recorded for root. Runs that fail before the
+// manifest is written should call it so records don't pile up.
+func ForgetOutputs(root string) {
+	takeOutputs(root)
+}
+
+// writeOutput writes dat into a file fp relative to root, making sure
+// all directories exist to host this file. The file is recorded for the
+// manifest.
+func writeOutput(root, fp string, dat []byte) error {
+	err := writeFile(root, fp, dat)
+	if err != nil {
+		return err
+	}
+
+	RecordOutput(root, fp)
+	return nil
+}
+
+// writeFile is like writeOutput but doesn't record the file, it's used
+// for files that describe the run rather than being a part of it.
+func writeFile(root, fp string, dat []byte) error {
+	dest := filepath.Join(root, fp)
+	err := os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(dest, dat, 0644)
+}
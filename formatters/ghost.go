@@ -0,0 +1,374 @@
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+type ghostExport struct {
+	DB []ghostDB `json:"db"`
+}
+
+type ghostDB struct {
+	Meta ghostMeta `json:"meta"`
+	Data ghostData `json:"data"`
+}
+
+type ghostMeta struct {
+	ExportedOn int64  `json:"exported_on"`
+	Version    string `json:"version"`
+}
+
+type ghostData struct {
+	Posts        []ghostPost       `json:"posts"`
+	Users        []ghostUser       `json:"users"`
+	PostsAuthors []ghostPostAuthor `json:"posts_authors"`
+	Tags         []ghostTag        `json:"tags"`
+	PostsTags    []ghostPostTag    `json:"posts_tags"`
+}
+
+type ghostPost struct {
+	Id           string `json:"id"`
+	Title        string `json:"title"`
+	Slug         string `json:"slug"`
+	Mobiledoc    string `json:"mobiledoc"`
+	Status       string `json:"status"`
+	Type         string `json:"type"`
+	CanonicalUrl string `json:"canonical_url,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	PublishedAt  string `json:"published_at,omitempty"`
+}
+
+type ghostUser struct {
+	Id           string `json:"id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	Email        string `json:"email"`
+	Bio          string `json:"bio,omitempty"`
+	ProfileImage string `json:"profile_image,omitempty"`
+	Website      string `json:"website,omitempty"`
+}
+
+type ghostTag struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type ghostPostTag struct {
+	PostId string `json:"post_id"`
+	TagId  string `json:"tag_id"`
+}
+
+type ghostPostAuthor struct {
+	PostId   string `json:"post_id"`
+	AuthorId string `json:"author_id"`
+}
+
+// GhostFormatter writes all posts into a single JSON file in Ghost's
+// import format. Post content is converted into mobiledoc with cards for
+// images, code blocks and embeds. All other export data is passed along to
+// JSONFormatter.
+//
+// GhostFormatter writes ghost.json on Close.
+type GhostFormatter struct {
+	jsonFallback
+	logger  *log.Logger
+	root    string
+	posts   []schema.Post
+	names   []string
+	profile *schema.Profile
+}
+
+func NewGhostFormatter(root string, logger *log.Logger) *GhostFormatter {
+	return &GhostFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		posts:        []schema.Post{},
+		names:        []string{},
+	}
+}
+
+func (w *GhostFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Post:
+		w.posts = append(w.posts, v)
+		w.names = append(w.names, filepath.Base(fp))
+		return nil
+	case schema.Profile:
+		w.profile = &v
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close writes ghost.json
+func (w *GhostFormatter) Close() error {
+	data := ghostData{
+		Posts:        []ghostPost{},
+		Users:        []ghostUser{},
+		PostsAuthors: []ghostPostAuthor{},
+		Tags:         []ghostTag{},
+		PostsTags:    []ghostPostTag{},
+	}
+
+	// Ghost requires an email for every user. Without one we leave authors
+	// out and Ghost assigns posts to whoever runs the import.
+	author := ""
+	if w.profile != nil && w.profile.User != nil && w.profile.Email != "" {
+		user := w.profile.User
+		author = "1"
+
+		u := ghostUser{
+			Id:      author,
+			Name:    user.Name,
+			Slug:    user.Username,
+			Email:   w.profile.Email,
+			Bio:     user.Bio,
+			Website: user.Url,
+		}
+
+		if u.Name == "" {
+			u.Name = user.Username
+		}
+
+		if user.ProfilePic != nil {
+			u.ProfileImage = imageSource(user.ProfilePic)
+		}
+
+		data.Users = append(data.Users, u)
+	}
+
+	for i, post := range w.posts {
+		doc, err := json.Marshal(mobiledocPost(stripTitle(post)))
+		if err != nil {
+			w.logger.Printf("can't convert %s into mobiledoc: %v", w.names[i], err)
+			return err
+		}
+
+		p := ghostPost{
+			Id:           fmt.Sprintf("%d", i+1),
+			Title:        post.Title,
+			Slug:         util.ParseMediumSlug(post.Url),
+			Mobiledoc:    string(doc),
+			Status:       "draft",
+			Type:         "post",
+			CanonicalUrl: post.Url,
+		}
+
+		if p.Slug == "" {
+			p.Slug = slugify(w.names[i])
+		}
+
+		if _, err := time.Parse(time.RFC3339, post.PublishedAt); err == nil {
+			p.Status = "published"
+			p.CreatedAt = post.PublishedAt
+			p.PublishedAt = post.PublishedAt
+		}
+
+		data.Posts = append(data.Posts, p)
+
+		if author != "" {
+			data.PostsAuthors = append(data.PostsAuthors, ghostPostAuthor{PostId: p.Id, AuthorId: author})
+		}
+
+		// Responses get an internal tag (hidden from readers) so themes
+		// can leave them out of the front page
+		if post.Kind == schema.RESPONSE {
+			if len(data.Tags) == 0 {
+				data.Tags = append(data.Tags, ghostTag{Id: "1", Name: "#response", Slug: "hash-response"})
+			}
+			data.PostsTags = append(data.PostsTags, ghostPostTag{PostId: p.Id, TagId: "1"})
+		}
+	}
+
+	out, err := json.MarshalIndent(ghostExport{
+		DB: []ghostDB{{
+			Meta: ghostMeta{ExportedOn: time.Now().UnixMilli(), Version: "5.0.0"},
+			Data: data,
+		}},
+	}, "", "  ")
+	if err != nil {
+		w.logger.Printf("can't marshal ghost.json: %v", err)
+		return err
+	}
+
+	err = writeOutput(w.root, "ghost.json", out)
+	if err != nil {
+		w.logger.Printf("can't write ghost.json: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// mobiledoc is a document in Ghost's mobiledoc 0.3.1 format. Sections,
+// cards, atoms and markups are heterogeneous JSON arrays.
+type mobiledoc struct {
+	Version  string `json:"version"`
+	Atoms    []any  `json:"atoms"`
+	Cards    []any  `json:"cards"`
+	Markups  []any  `json:"markups"`
+	Sections []any  `json:"sections"`
+}
+
+const (
+	mobiledocMarkupSection = 1
+	mobiledocListSection   = 3
+	mobiledocCardSection   = 10
+	mobiledocTextMarker    = 0
+	mobiledocAtomMarker    = 1
+)
+
+func mobiledocPost(post schema.Post) mobiledoc {
+	doc := mobiledoc{
+		Version:  "0.3.1",
+		Atoms:    []any{},
+		Cards:    []any{},
+		Markups:  []any{},
+		Sections: []any{},
+	}
+
+	card := func(name string, payload map[string]any) {
+		doc.Sections = append(doc.Sections, []any{mobiledocCardSection, len(doc.Cards)})
+		doc.Cards = append(doc.Cards, []any{name, payload})
+	}
+
+	for i, s := range post.Content {
+		if i > 0 {
+			card("hr", map[string]any{})
+		}
+
+		for _, inner := range s.Body {
+			for _, g := range inner.Body {
+				switch g.Type {
+				case schema.H1, schema.H2, schema.H3, schema.H4:
+					doc.Sections = append(doc.Sections, []any{mobiledocMarkupSection, string(g.Type), doc.markers(g)})
+				case schema.P:
+					doc.Sections = append(doc.Sections, []any{mobiledocMarkupSection, "p", doc.markers(g)})
+				case schema.BLOCKQUOTE:
+					doc.Sections = append(doc.Sections, []any{mobiledocMarkupSection, "blockquote", doc.markers(g)})
+				case schema.OL, schema.UL:
+					items := []any{}
+					for _, item := range g.Items {
+						items = append(items, doc.markers(item))
+					}
+					doc.Sections = append(doc.Sections, []any{mobiledocListSection, string(g.Type), items})
+				case schema.PRE:
+					card("code", map[string]any{"code": strings.TrimRight(g.Text, "\n")})
+				case schema.IMG:
+					images := grafImages(g)
+					if len(images) == 0 {
+						continue
+					}
+
+					payload := map[string]any{}
+					if g.Text != "" {
+						payload["caption"] = htmlInline(g.Text, g.Markups)
+					}
+
+					if len(images) == 1 {
+						payload["src"] = imageSource(images[0])
+						payload["alt"] = images[0].Alt
+						if width := ghostCardWidth(g.Layout); width != "" {
+							payload["cardWidth"] = width
+						}
+						card("image", payload)
+						continue
+					}
+
+					// Ghost galleries have up to three images in a row
+					gallery := []any{}
+					for i, img := range images {
+						item := map[string]any{"fileName": img.Name, "src": imageSource(img), "alt": img.Alt, "row": i / 3}
+						if width, err := strconv.Atoi(img.Width); err == nil {
+							item["width"] = width
+						}
+						if height, err := strconv.Atoi(img.Height); err == nil {
+							item["height"] = height
+						}
+						gallery = append(gallery, item)
+					}
+					payload["images"] = gallery
+					card("gallery", payload)
+				case schema.EMBED:
+					if g.Embed != nil {
+						card("html", map[string]any{"html": "<p>" + htmlEmbed(g) + "</p>"})
+					} else {
+						card("html", map[string]any{"html": "<p>" + htmlInline(g.Text, g.Markups) + "</p>"})
+					}
+				case schema.HR:
+					card("hr", map[string]any{})
+				}
+			}
+		}
+	}
+
+	return doc
+}
+
+// ghostCardWidth maps Medium layouts to widths of Ghost image cards
+func ghostCardWidth(l schema.Layout) string {
+	switch l {
+	case schema.OUTSET_CENTER:
+		return "wide"
+	case schema.FILL_WIDTH:
+		return "full"
+	}
+	return ""
+}
+
+// markers converts graf text and markups into mobiledoc markers. Every
+// marker lists markups that open before it and the number of markups that
+// close right after it.
+func (doc *mobiledoc) markers(g schema.Graf) []any {
+	markers := []any{}
+	opens := []int{}
+
+	for _, t := range tokenize(g.Text, g.Markups) {
+		switch t.kind {
+		case tokenOpen, tokenClose:
+			var markup []any
+			switch t.markup.Type {
+			case schema.EM:
+				markup = []any{"em"}
+			case schema.STRONG:
+				markup = []any{"strong"}
+			case schema.A:
+				markup = []any{"a", []any{"href", t.markup.Href}}
+			default:
+				// Ghost doesn't support highlights
+				continue
+			}
+
+			if t.kind == tokenOpen {
+				opens = append(opens, len(doc.Markups))
+				doc.Markups = append(doc.Markups, markup)
+				continue
+			}
+
+			if len(markers) > 0 {
+				m := markers[len(markers)-1].([]any)
+				m[2] = m[2].(int) + 1
+			}
+		case tokenText:
+			markers = append(markers, []any{mobiledocTextMarker, opens, 0, t.text})
+			opens = []int{}
+		case tokenBreak:
+			markers = append(markers, []any{mobiledocAtomMarker, opens, 0, len(doc.Atoms)})
+			doc.Atoms = append(doc.Atoms, []any{"soft-return", "", map[string]any{}})
+			opens = []int{}
+		}
+	}
+
+	return markers
+}
@@ -0,0 +1,111 @@
+package formatters_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestGhostFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewGhostFormatter(root, log.New(io.Discard, "", 0))
+
+	w.WriteFile("posts/basic", schema.Post{
+		Url:         "https://medium.com/@anton/oh-right-70c5683f3778",
+		Title:       "oh, right",
+		PublishedAt: "2015-02-05T02:56:45.739Z",
+		Content: []schema.Section{
+			{Body: []schema.InnerSection{{Body: []schema.Graf{
+				{
+					Type: schema.P,
+					Text: "The owls are not",
+					Markups: []schema.Markup{
+						{Type: schema.A, Start: 4, End: 12, Href: "https://owls.com"},
+						{Type: schema.STRONG, Start: 9, End: 12},
+						{Type: schema.BR, Start: 12, End: 12},
+					},
+				},
+				{Type: schema.PRE, Text: "x := 1\n"},
+			}}}},
+		},
+	})
+	w.WriteFile("posts/reply", schema.Post{Title: "Nice!", Kind: schema.RESPONSE})
+	w.WriteFile("profile", schema.Profile{
+		Email: "anton@example.com",
+		User:  &schema.User{Username: "anton", Name: "Anton Kovalyov"},
+	})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	dat, _ := os.ReadFile(filepath.Join(root, "ghost.json"))
+
+	var export struct {
+		DB []struct {
+			Data struct {
+				Posts []struct {
+					Slug      string `json:"slug"`
+					Status    string `json:"status"`
+					Mobiledoc string `json:"mobiledoc"`
+				} `json:"posts"`
+				Users        []map[string]any `json:"users"`
+				PostsAuthors []map[string]any `json:"posts_authors"`
+				Tags         []map[string]any `json:"tags"`
+				PostsTags    []map[string]any `json:"posts_tags"`
+			} `json:"data"`
+		} `json:"db"`
+	}
+
+	err = json.Unmarshal(dat, &export)
+	if err != nil {
+		t.Fatalf("ghost.json is not valid JSON: %v", err)
+	}
+
+	data := export.DB[0].Data
+	if len(data.PostsTags) != 1 || data.PostsTags[0]["post_id"] != "2" || data.Tags[0]["name"] != "#response" {
+		t.Errorf("response isn't tagged: %v %v", data.Tags, data.PostsTags)
+	}
+
+	if len(data.Users) != 1 || len(data.PostsAuthors) != 2 {
+		t.Errorf("author is missing")
+	}
+
+	post := data.Posts[0]
+	if post.Slug != "oh-right" || post.Status != "published" {
+		t.Errorf("wrong post metadata: %+v", post)
+	}
+
+	var have any
+	json.Unmarshal([]byte(post.Mobiledoc), &have)
+
+	var want any
+	json.Unmarshal([]byte(`{
+		"version": "0.3.1",
+		"atoms": [["soft-return", "", {}]],
+		"cards": [["code", {"code": "x := 1"}]],
+		"markups": [["a", ["href", "https://owls.com"]], ["strong"]],
+		"sections": [
+			[1, "p", [
+				[0, [], 0, "The "],
+				[0, [0], 0, "owls "],
+				[0, [1], 2, "are"],
+				[1, [], 0, 0],
+				[0, [], 0, " not"]
+			]],
+			[10, 0]
+		]
+	}`), &want)
+
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want: %v\nhave: %v", want, have)
+	}
+}
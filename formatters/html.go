@@ -0,0 +1,349 @@
+package formatters
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valueof/meh/schema"
+)
+
+var htmlPage = template.Must(template.New("post").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{if .Url}}<link rel="canonical" href="{{.Url}}">
+{{end}}<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font: 18px/1.6 Georgia, Cambria, "Times New Roman", Times, serif; color: #222; }
+header time { color: #666; }
+img { max-width: 100%; height: auto; }
+figure { margin: 1.5em 0; }
+figure.grid { display: flex; flex-wrap: wrap; gap: 0.5em; }
+figure.grid img { flex: 1; min-width: 0; }
+figure.grid figcaption { flex-basis: 100%; }
+figcaption { color: #666; font-size: 15px; text-align: center; }
+pre { overflow-x: auto; padding: 1em; background: #f5f5f5; font-size: 15px; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 3px solid #ccc; font-style: italic; }
+section + section { margin-top: 2em; }
+</style>
+</head>
+<body>
+<article>
+<header>
+<h1>{{.Title}}</h1>
+{{if .PublishedAt}}<p><time datetime="{{.PublishedAt}}">{{.Date}}</time></p>
+{{end}}</header>
+{{.Body}}
+<footer>
+{{if .Url}}<p><a href="{{.Url}}">Originally published on Medium</a></p>
+{{end}}<p><a href="{{.Index}}">All posts</a></p>
+</footer>
+</article>
+</body>
+</html>
+`))
+
+var htmlIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Posts</title>
+<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font: 18px/1.6 Georgia, Cambria, "Times New Roman", Times, serif; color: #222; }
+ul { list-style: none; padding: 0; }
+li { margin-bottom: 0.5em; }
+time { color: #666; margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>Posts</h1>
+<ul>
+{{range .}}<li>{{if .PublishedAt}}<time datetime="{{.PublishedAt}}">{{.Date}}</time>{{end}}<a href="{{.Path}}">{{.Title}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+type htmlEntry struct {
+	Title       string
+	Url         string
+	PublishedAt string
+	Date        string
+	Path        string
+	Index       string
+	Body        template.HTML
+}
+
+// HTMLFormatter converts posts into clean, self-contained HTML pages and
+// writes an index.html that lists all posts. Images point to the images/
+// directory populated by Parser.FetchImages. All other export data is passed
+// along to JSONFormatter.
+//
+// HTMLFormatter writes the index page on Close.
+type HTMLFormatter struct {
+	jsonFallback
+	logger *log.Logger
+	root   string
+	posts  []htmlEntry
+}
+
+func NewHTMLFormatter(root string, logger *log.Logger) *HTMLFormatter {
+	return &HTMLFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		posts:        []htmlEntry{},
+	}
+}
+
+func (w *HTMLFormatter) WriteFile(fp string, v any) error {
+	post, ok := v.(schema.Post)
+	if !ok {
+		return w.fallback(fp, v)
+	}
+
+	// Relative path from the post to the output root
+	up := strings.Repeat("../", strings.Count(filepath.ToSlash(fp), "/"))
+
+	entry := htmlEntry{
+		Title:       post.Title,
+		Url:         post.Url,
+		PublishedAt: post.PublishedAt,
+		Date:        displayDate(post.PublishedAt),
+		Path:        filepath.ToSlash(fp) + ".html",
+		Index:       up + "index.html",
+		Body:        template.HTML(htmlPost(stripTitle(post), localImage(up+"images/"))),
+	}
+
+	var b bytes.Buffer
+	err := htmlPage.Execute(&b, entry)
+	if err != nil {
+		w.logger.Printf("can't render %s.html: %v", fp, err)
+		return err
+	}
+
+	err = writeOutput(w.root, fp+".html", b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write %s.html: %v", fp, err)
+		return err
+	}
+
+	entry.Body = ""
+	w.posts = append(w.posts, entry)
+	return nil
+}
+
+// Close writes index.html with all posts, newest first. Posts without a
+// publication date (drafts) go last.
+func (w *HTMLFormatter) Close() error {
+	sort.SliceStable(w.posts, func(i, j int) bool {
+		a, b := w.posts[i].PublishedAt, w.posts[j].PublishedAt
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		return a > b
+	})
+
+	var b bytes.Buffer
+	err := htmlIndex.Execute(&b, w.posts)
+	if err != nil {
+		w.logger.Printf("can't render index.html: %v", err)
+		return err
+	}
+
+	err = writeOutput(w.root, "index.html", b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write index.html: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// htmlPost renders post content as semantic HTML. Sections and inner
+// sections become <section> elements, inner sections keep their Medium
+// layout as a class name (insetColumn, outsetColumn, fullWidth).
+//
+// The output is also well-formed XHTML so it can be used in e-books.
+func htmlPost(post schema.Post, src func(*schema.Image) string) string {
+	var b strings.Builder
+
+	for _, s := range post.Content {
+		b.WriteString("<section>\n")
+		for _, inner := range s.Body {
+			classes := []string{}
+			for _, c := range inner.Classes {
+				classes = append(classes, strings.TrimPrefix(c, "sectionLayout--"))
+			}
+
+			if len(classes) > 0 {
+				fmt.Fprintf(&b, "<section class=\"%s\">\n", html.EscapeString(strings.Join(classes, " ")))
+			} else {
+				b.WriteString("<section>\n")
+			}
+
+			for _, g := range inner.Body {
+				if s := htmlGraf(g, src); s != "" {
+					b.WriteString(s)
+					b.WriteString("\n")
+				}
+			}
+			b.WriteString("</section>\n")
+		}
+		b.WriteString("</section>\n")
+	}
+
+	return b.String()
+}
+
+// htmlGraf renders a single graf. Image sources are resolved by src, images
+// with an empty source are left out.
+func htmlGraf(g schema.Graf, src func(*schema.Image) string) string {
+	switch g.Type {
+	case schema.H1, schema.H2, schema.H3, schema.H4:
+		tag := "h" + strings.TrimPrefix(string(g.Type), "h")
+		return fmt.Sprintf("<%s>%s</%s>", tag, htmlInline(g.Text, g.Markups), tag)
+	case schema.P:
+		return "<p>" + htmlInline(g.Text, g.Markups) + "</p>"
+	case schema.EMBED:
+		if g.Embed != nil {
+			return fmt.Sprintf("<p class=\"embed embed--%s\">%s</p>", g.Embed.Provider, htmlEmbed(g))
+		}
+		return "<p class=\"embed\">" + htmlInline(g.Text, g.Markups) + "</p>"
+	case schema.BLOCKQUOTE:
+		return "<blockquote><p>" + htmlInline(g.Text, g.Markups) + "</p></blockquote>"
+	case schema.PRE:
+		return "<pre><code>" + html.EscapeString(strings.TrimRight(g.Text, "\n")) + "</code></pre>"
+	case schema.IMG:
+		images := []string{}
+		for _, img := range grafImages(g) {
+			url := src(img)
+			if url == "" {
+				continue
+			}
+
+			attrs := ""
+			if img.Width != "" && img.Height != "" {
+				attrs = fmt.Sprintf(" width=\"%s\" height=\"%s\"", html.EscapeString(img.Width), html.EscapeString(img.Height))
+			}
+
+			images = append(images, fmt.Sprintf("<img src=\"%s\" alt=\"%s\"%s/>", html.EscapeString(url), html.EscapeString(img.Alt), attrs))
+		}
+
+		if len(images) == 0 {
+			return ""
+		}
+
+		class := ""
+		if g.Layout != "" {
+			class = fmt.Sprintf(" class=\"%s\"", g.Layout)
+		}
+
+		caption := ""
+		if g.Text != "" {
+			caption = "<figcaption>" + htmlInline(g.Text, g.Markups) + "</figcaption>"
+		}
+
+		return fmt.Sprintf("<figure%s>%s%s</figure>", class, strings.Join(images, ""), caption)
+	case schema.OL, schema.UL:
+		items := []string{}
+		for _, item := range g.Items {
+			items = append(items, "<li>"+htmlInline(item.Text, item.Markups)+"</li>")
+		}
+		return fmt.Sprintf("<%s>%s</%s>", g.Type, strings.Join(items, ""), g.Type)
+	case schema.HR:
+		return "<hr/>"
+	}
+
+	return ""
+}
+
+// htmlEmbed renders an embed as a link followed by its description or
+// caption, see markdownEmbed.
+func htmlEmbed(g schema.Graf) string {
+	e := g.Embed
+	s := fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(e.Source), html.EscapeString(embedLabel(e)))
+	if e.Description != "" {
+		s += "<br/>" + html.EscapeString(e.Description)
+	}
+	if e.Title == "" && g.Text != "" {
+		s += "<br/>" + htmlInline(g.Text, g.Markups)
+	}
+	return s
+}
+
+// embedLabel returns text for a link to an embed
+func embedLabel(e *schema.Embed) string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return e.Source
+}
+
+// htmlInline renders graf text with its markups as nested inline tags.
+func htmlInline(text string, markups []schema.Markup) string {
+	var b strings.Builder
+
+	for _, t := range tokenize(text, markups) {
+		switch t.kind {
+		case tokenText:
+			b.WriteString(html.EscapeString(t.text))
+		case tokenBreak:
+			b.WriteString("<br/>")
+		case tokenOpen:
+			switch t.markup.Type {
+			case schema.EM:
+				b.WriteString("<em>")
+			case schema.STRONG:
+				b.WriteString("<strong>")
+			case schema.A:
+				fmt.Fprintf(&b, "<a href=\"%s\">", html.EscapeString(t.markup.Href))
+			case schema.HIGHLIGHT:
+				b.WriteString("<mark>")
+			}
+		case tokenClose:
+			switch t.markup.Type {
+			case schema.EM:
+				b.WriteString("</em>")
+			case schema.STRONG:
+				b.WriteString("</strong>")
+			case schema.A:
+				b.WriteString("</a>")
+			case schema.HIGHLIGHT:
+				b.WriteString("</mark>")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// localImage returns an image resolver that points images to a local
+// directory dir. Images without a name are loaded from Medium CDN.
+func localImage(dir string) func(*schema.Image) string {
+	return func(img *schema.Image) string {
+		if img.Name == "" {
+			return imageSource(img)
+		}
+		return dir + img.Name
+	}
+}
+
+// displayDate formats an RFC 3339 timestamp for humans. Anything else is
+// returned as is.
+func displayDate(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.Format("January 2, 2006")
+}
@@ -0,0 +1,87 @@
+package formatters_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestHTMLFormatter(t *testing.T) {
+	posts := map[string]schema.Post{
+		"old": {Title: "Old", PublishedAt: "2015-02-05T02:56:45.739Z"},
+		"new": {
+			Title:       "New",
+			PublishedAt: "2022-04-05T10:00:00.000Z",
+			Content: []schema.Section{
+				{Body: []schema.InnerSection{{
+					Classes: []string{"sectionLayout--insetColumn"},
+					Body: []schema.Graf{
+						{
+							Type: schema.P,
+							Text: "The owls are <not>",
+							Markups: []schema.Markup{
+								{Type: schema.EM, Start: 4, End: 12},
+								{Type: schema.STRONG, Start: 9, End: 18},
+							},
+						},
+						{Type: schema.IMG, Image: &schema.Image{Name: "owl.png"}},
+						{
+							Type:    schema.IMG,
+							Text:    "Feeder regulars",
+							Layout:  schema.GRID,
+							Images:  []schema.Image{{Name: "finch.png", Alt: "Finch"}, {Name: "wren.png", Alt: "Wren"}},
+							Markups: []schema.Markup{{Type: schema.EM, Start: 0, End: 6}},
+						},
+						{
+							Type: schema.UL,
+							Items: []schema.Graf{
+								{Type: schema.LI, Text: "Herons", Markups: []schema.Markup{{Type: schema.STRONG, Start: 0, End: 6}}},
+								{Type: schema.LI, Text: "Woodpeckers"},
+							},
+						},
+					},
+				}}},
+			},
+		},
+		"draft": {Title: "Draft"},
+	}
+
+	root := t.TempDir()
+	w := formatters.NewHTMLFormatter(root, log.New(os.Stdout, "", 0))
+
+	for name, post := range posts {
+		err := w.WriteFile(filepath.Join("posts", name), post)
+		if err != nil {
+			t.Fatalf("can't write %s: %v", name, err)
+		}
+	}
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	have, _ := os.ReadFile(filepath.Join(root, "posts", "new.html"))
+	for _, want := range []string{
+		`<section class="insetColumn">`,
+		`<p>The <em>owls <strong>are</strong></em><strong> &lt;not&gt;</strong></p>`,
+		`<figure><img src="../images/owl.png" alt=""/></figure>`,
+		`<figure class="grid"><img src="../images/finch.png" alt="Finch"/><img src="../images/wren.png" alt="Wren"/><figcaption><em>Feeder</em> regulars</figcaption></figure>`,
+		`<ul><li><strong>Herons</strong></li><li>Woodpeckers</li></ul>`,
+	} {
+		if !strings.Contains(string(have), want) {
+			t.Errorf("posts/new.html doesn't contain %s", want)
+		}
+	}
+
+	index, _ := os.ReadFile(filepath.Join(root, "index.html"))
+	n, o, d := strings.Index(string(index), "posts/new.html"), strings.Index(string(index), "posts/old.html"), strings.Index(string(index), "posts/draft.html")
+	if n == -1 || o == -1 || d == -1 || !(n < o && o < d) {
+		t.Errorf("index.html lists posts in the wrong order:\n%s", index)
+	}
+}
@@ -0,0 +1,212 @@
+package formatters
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/valueof/meh/schema"
+)
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenBreak
+)
+
+// inlineToken is a piece of graf text with markups applied to it. Formatters
+// turn a stream of tokens into their own inline syntax.
+type inlineToken struct {
+	kind   tokenKind
+	text   string
+	markup schema.Markup
+}
+
+// tokenize applies markups to a given text and returns a stream of tokens
+// where every open token is matched by a close token in the right order.
+//
+// Markup offsets in schema.Markup are byte offsets into the text and spans
+// can overlap. Overlapping spans are split so that the result is always
+// properly nested:
+//
+//	text:    The owls are not
+//	markups: em [4, 12), strong [9, 16)
+//	tokens:  The <em>owls <strong>are</strong></em><strong> not</strong>
+func tokenize(text string, markups []schema.Markup) []inlineToken {
+	spans := []schema.Markup{}
+	breaks := map[int]int{}
+	points := []int{0, len(text)}
+
+	for _, m := range markups {
+		m.Start = clampOffset(text, m.Start)
+		m.End = clampOffset(text, m.End)
+
+		if m.Type == schema.BR {
+			breaks[m.Start]++
+			points = append(points, m.Start)
+			continue
+		}
+
+		if m.End <= m.Start {
+			continue
+		}
+
+		spans = append(spans, m)
+		points = append(points, m.Start, m.End)
+	}
+
+	sort.Ints(points)
+
+	tokens := []inlineToken{}
+	stack := []int{}
+	last := -1
+
+	for i, pos := range points {
+		if pos == last {
+			continue
+		}
+		last = pos
+
+		next := len(text)
+		for _, p := range points[i:] {
+			if p > pos {
+				next = p
+				break
+			}
+		}
+
+		// Spans that should be open for the text between pos and next, in
+		// the order they need to be opened.
+		active := []int{}
+		if pos < len(text) {
+			for j, m := range spans {
+				if m.Start <= pos && m.End > pos {
+					active = append(active, j)
+				}
+			}
+		}
+
+		sort.SliceStable(active, func(a, b int) bool {
+			ma, mb := spans[active[a]], spans[active[b]]
+			if ma.Start != mb.Start {
+				return ma.Start < mb.Start
+			}
+			return ma.End > mb.End
+		})
+
+		common := 0
+		for common < len(stack) && common < len(active) && stack[common] == active[common] {
+			common++
+		}
+
+		for j := len(stack) - 1; j >= common; j-- {
+			tokens = append(tokens, inlineToken{kind: tokenClose, markup: spans[stack[j]]})
+		}
+		stack = stack[:common]
+
+		for j := 0; j < breaks[pos]; j++ {
+			tokens = append(tokens, inlineToken{kind: tokenBreak, markup: schema.Markup{Type: schema.BR}})
+		}
+
+		for _, j := range active[common:] {
+			tokens = append(tokens, inlineToken{kind: tokenOpen, markup: spans[j]})
+			stack = append(stack, j)
+		}
+
+		if next > pos {
+			tokens = append(tokens, inlineToken{kind: tokenText, text: text[pos:next]})
+		}
+	}
+
+	return tokens
+}
+
+// hoistSpace moves leading and trailing whitespace out of markup spans and
+// drops spans that end up empty. Lightweight markup languages, such as
+// Markdown, don't recognize emphasis like "** bold **".
+func hoistSpace(tokens []inlineToken) []inlineToken {
+	out := []inlineToken{}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokenText {
+			out = append(out, t)
+			continue
+		}
+
+		// Leading space goes before all open tokens that precede this text.
+		lead := len(t.text) - len(strings.TrimLeftFunc(t.text, unicode.IsSpace))
+		if lead > 0 {
+			j := len(out)
+			for j > 0 && out[j-1].kind == tokenOpen {
+				j--
+			}
+			if j < len(out) {
+				space := inlineToken{kind: tokenText, text: t.text[:lead]}
+				out = append(out[:j], append([]inlineToken{space}, out[j:]...)...)
+				t.text = t.text[lead:]
+			}
+		}
+
+		// Trailing space goes after all close tokens that follow this text.
+		trail := len(t.text) - len(strings.TrimRightFunc(t.text, unicode.IsSpace))
+		closes := 0
+		for i+1+closes < len(tokens) && tokens[i+1+closes].kind == tokenClose {
+			closes++
+		}
+
+		if trail > 0 && closes > 0 {
+			space := inlineToken{kind: tokenText, text: t.text[len(t.text)-trail:]}
+			t.text = t.text[:len(t.text)-trail]
+			if t.text != "" {
+				out = append(out, t)
+			}
+			out = append(out, tokens[i+1:i+1+closes]...)
+			out = append(out, space)
+			i += closes
+			continue
+		}
+
+		if t.text != "" {
+			out = append(out, t)
+		}
+	}
+
+	// Remove spans that don't wrap anything.
+	for {
+		changed := false
+		for i := 0; i+1 < len(out); i++ {
+			if out[i].kind == tokenOpen && out[i+1].kind == tokenClose {
+				out = append(out[:i], out[i+2:]...)
+				changed = true
+				break
+			}
+		}
+
+		if !changed {
+			return out
+		}
+	}
+}
+
+// clampOffset makes sure that a markup offset points inside the text and
+// at the beginning of a UTF-8 sequence.
+func clampOffset(s string, n int) int {
+	if n < 0 {
+		return 0
+	}
+
+	if n > len(s) {
+		return len(s)
+	}
+
+	for n < len(s) && !utf8.RuneStart(s[n]) {
+		n++
+	}
+
+	return n
+}
@@ -11,11 +11,11 @@ import (
 // distinguish between different types of data. It creates a new
 // .json file per each invokation of WriteFile.
 type JSONFormatter struct {
-	logger log.Logger
+	logger *log.Logger
 	root   string
 }
 
-func NewJSONFormatter(root string, logger log.Logger) *JSONFormatter {
+func NewJSONFormatter(root string, logger *log.Logger) *JSONFormatter {
 	return &JSONFormatter{
 		logger: logger,
 		root:   root,
@@ -44,5 +44,51 @@ func (w *JSONFormatter) WriteFile(fp string, v any) error {
 		return err
 	}
 
+	RecordOutput(w.root, fp+".json")
 	return nil
 }
+
+// jsonFallback is embedded by formatters that pass export data along to
+// JSONFormatter. NewMulti points all of them to a single sharedJSON (or
+// turns them off when json is one of the formats) so that several
+// formats don't write the same JSON files over and over again.
+type jsonFallback struct {
+	json Formatter
+}
+
+func newJSONFallback(root string, logger *log.Logger) jsonFallback {
+	return jsonFallback{json: NewJSONFormatter(root, logger)}
+}
+
+func (f *jsonFallback) fallback(fp string, v any) error {
+	if f.json == nil {
+		return nil
+	}
+	return f.json.WriteFile(fp, v)
+}
+
+func (f *jsonFallback) setFallback(json Formatter) {
+	f.json = json
+}
+
+// sharedJSON is a JSONFormatter that writes every file only once, no
+// matter how many formatters pass it along.
+type sharedJSON struct {
+	json    *JSONFormatter
+	written map[string]bool
+}
+
+func newSharedJSON(root string, logger *log.Logger) *sharedJSON {
+	return &sharedJSON{
+		json:    NewJSONFormatter(root, logger),
+		written: map[string]bool{},
+	}
+}
+
+func (s *sharedJSON) WriteFile(fp string, v any) error {
+	if s.written[fp] {
+		return nil
+	}
+	s.written[fp] = true
+	return s.json.WriteFile(fp, v)
+}
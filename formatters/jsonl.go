@@ -0,0 +1,189 @@
+package formatters
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/valueof/meh/schema"
+)
+
+// JSONLRecord is a single line in the JSON Lines output. Kind tells what
+// dataset the record comes from (clap, bookmark, post, etc.)
+type JSONLRecord struct {
+	Kind string `json:"kind"`
+	Data any    `json:"data"`
+}
+
+// JSONLFormatter writes export data into a single JSON Lines file, one
+// record per line. Records are written as soon as they come in, nothing
+// is kept in memory.
+//
+// JSONLFormatter flushes and closes meh.jsonl on Close.
+type JSONLFormatter struct {
+	logger *log.Logger
+	root   string
+	file   *os.File
+	out    *bufio.Writer
+	enc    *json.Encoder
+}
+
+func NewJSONLFormatter(root string, logger *log.Logger) *JSONLFormatter {
+	return &JSONLFormatter{
+		logger: logger,
+		root:   root,
+	}
+}
+
+func (w *JSONLFormatter) WriteFile(fp string, v any) error {
+	if w.enc == nil {
+		err := w.open()
+		if err != nil {
+			w.logger.Printf("can't create meh.jsonl: %v", err)
+			return err
+		}
+	}
+
+	switch v := v.(type) {
+	case schema.Post:
+		return w.write("post", v)
+	case schema.BlockedUsers:
+		for _, u := range v.Users {
+			if err := w.write("blocked_user", u); err != nil {
+				return err
+			}
+		}
+	case schema.Bookmarks:
+		for _, p := range v.Posts {
+			if err := w.write("bookmark", p); err != nil {
+				return err
+			}
+		}
+	case schema.Claps:
+		for _, c := range v.Claps {
+			if err := w.write("clap", c); err != nil {
+				return err
+			}
+		}
+	case schema.Highlights:
+		for _, h := range v.Highlights {
+			if err := w.write("highlight", h); err != nil {
+				return err
+			}
+		}
+	case schema.Interests:
+		for _, p := range v.Publications {
+			if err := w.write("interest_publication", p); err != nil {
+				return err
+			}
+		}
+		for _, t := range v.Tags {
+			if err := w.write("interest_tag", t); err != nil {
+				return err
+			}
+		}
+		for _, t := range v.Topics {
+			if err := w.write("interest_topic", t); err != nil {
+				return err
+			}
+		}
+		for _, u := range v.Writers {
+			if err := w.write("interest_writer", u); err != nil {
+				return err
+			}
+		}
+	case schema.IPs:
+		for _, ip := range v.IPs {
+			if err := w.write("ip", ip); err != nil {
+				return err
+			}
+		}
+	case schema.Lists:
+		for _, l := range v.Lists {
+			if err := w.write("list", l); err != nil {
+				return err
+			}
+		}
+	case schema.Publications:
+		for _, p := range v.Publications {
+			if err := w.write("following_publication", p); err != nil {
+				return err
+			}
+		}
+	case schema.Topics:
+		for _, t := range v.Topics {
+			if err := w.write("following_topic", t); err != nil {
+				return err
+			}
+		}
+	case schema.Users:
+		kind := "following_user"
+		if filepath.Base(fp) == "suggested" {
+			kind = "suggested_user"
+		}
+		for _, u := range v.Users {
+			if err := w.write(kind, u); err != nil {
+				return err
+			}
+		}
+	case schema.Sessions:
+		for _, s := range v.Sessions {
+			if err := w.write("session", s); err != nil {
+				return err
+			}
+		}
+	case schema.Profile:
+		return w.write("profile", v)
+	case schema.PostIndex:
+		// Indexes only repeat what post records already have
+		return nil
+	default:
+		w.logger.Printf("%s can't be written into meh.jsonl, skipping", fp)
+	}
+
+	return nil
+}
+
+// Close flushes and closes meh.jsonl
+func (w *JSONLFormatter) Close() error {
+	if w.out == nil {
+		return nil
+	}
+
+	err := w.out.Flush()
+	if err != nil {
+		w.logger.Printf("can't write meh.jsonl: %v", err)
+		w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+func (w *JSONLFormatter) open() error {
+	err := os.MkdirAll(w.root, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	w.file, err = os.Create(filepath.Join(w.root, "meh.jsonl"))
+	if err != nil {
+		return err
+	}
+	RecordOutput(w.root, "meh.jsonl")
+
+	w.out = bufio.NewWriter(w.file)
+	w.enc = json.NewEncoder(w.out)
+	w.enc.SetEscapeHTML(false)
+	return nil
+}
+
+func (w *JSONLFormatter) write(kind string, v any) error {
+	err := w.enc.Encode(JSONLRecord{Kind: kind, Data: v})
+	if err != nil {
+		w.logger.Printf("can't write %s record: %v", kind, err)
+	}
+	return err
+}
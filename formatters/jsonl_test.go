@@ -0,0 +1,70 @@
+package formatters_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestJSONLFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewJSONLFormatter(root, log.New(io.Discard, "", 0))
+
+	w.WriteFile("claps", schema.Claps{Claps: []schema.Clap{
+		{Post: schema.Post{Id: "9e53ca408c48", Title: "Welcome to Medium"}, Amount: 50},
+		{Post: schema.Post{Id: "b8d43e4c204d", Title: "Re-thinking J-school"}, Amount: 1},
+	}})
+	w.WriteFile("ips", schema.IPs{IPs: []schema.IP{{Address: "127.0.0.1"}}})
+	w.WriteFile("following/suggested", schema.Users{Users: []schema.User{{Username: "dpup"}}})
+	w.WriteFile("posts/basic", schema.Post{Id: "70c5683f3778", Title: "<oh, right>"})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(root, "meh.jsonl"))
+	if err != nil {
+		t.Fatalf("can't open meh.jsonl: %v", err)
+	}
+	defer f.Close()
+
+	kinds := []string{}
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec struct {
+			Kind string          `json:"kind"`
+			Data json.RawMessage `json:"data"`
+		}
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			t.Fatalf("%s is not valid JSON: %v", scanner.Text(), err)
+		}
+		kinds = append(kinds, rec.Kind)
+		lines = append(lines, scanner.Text())
+	}
+
+	want := []string{"clap", "clap", "ip", "suggested_user", "post"}
+	if len(kinds) != len(want) {
+		t.Fatalf("want %d records; have %d", len(want), len(kinds))
+	}
+
+	for i, k := range want {
+		if kinds[i] != k {
+			t.Errorf("record %d: want kind %s; have %s", i, k, kinds[i])
+		}
+	}
+
+	post := `{"kind":"post","data":{"id":"70c5683f3778","url":"","title":"<oh, right>"}}`
+	if lines[4] != post {
+		t.Errorf("want %s; have %s", post, lines[4])
+	}
+}
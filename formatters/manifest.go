@@ -0,0 +1,175 @@
+package formatters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/valueof/meh/schema"
+)
+
+// ManifestWriter writes manifest.json: a record of a conversion with the
+// meh version, time of the run, options, number of items in every dataset,
+// and size and SHA-256 of every file written into root during the run (see
+// RecordOutput). Files are listed in Close so the manifest writer has to be
+// closed after all other writers, see NewMultiWriter.
+type ManifestWriter struct {
+	NopWriter
+	logger   *log.Logger
+	root     string
+	manifest schema.Manifest
+}
+
+// NewManifestWriter creates a manifest writer. Options are copied into the
+// manifest as is, they should describe how meh was called.
+func NewManifestWriter(root, version string, options map[string]any, logger *log.Logger) *ManifestWriter {
+	if options == nil {
+		options = map[string]any{}
+	}
+
+	return &ManifestWriter{
+		logger: logger,
+		root:   root,
+		manifest: schema.Manifest{
+			Version: version,
+			Options: options,
+			Counts:  map[string]int{},
+			Files:   []schema.ManifestFile{},
+		},
+	}
+}
+
+func (w *ManifestWriter) Begin() error {
+	w.manifest.StartedAt = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+func (w *ManifestWriter) WritePost(name string, post schema.Post) error {
+	w.manifest.Counts["posts"]++
+	w.manifest.Counts["posts/"+postDir(post)]++
+	if w.manifest.ExportedAt == "" {
+		w.manifest.ExportedAt = post.ExportedAt
+	}
+	return nil
+}
+
+func (w *ManifestWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	w.manifest.Counts["blocks"] += len(v.Users)
+	return nil
+}
+
+func (w *ManifestWriter) WriteBookmarks(v schema.Bookmarks) error {
+	w.manifest.Counts["bookmarks"] += len(v.Posts)
+	return nil
+}
+
+func (w *ManifestWriter) WriteClaps(v schema.Claps) error {
+	w.manifest.Counts["claps"] += len(v.Claps)
+	return nil
+}
+
+func (w *ManifestWriter) WriteHighlights(v schema.Highlights) error {
+	w.manifest.Counts["highlights"] += len(v.Highlights)
+	return nil
+}
+
+func (w *ManifestWriter) WriteInterests(v schema.Interests) error {
+	w.manifest.Counts["interests"] += len(v.Publications) + len(v.Tags) + len(v.Topics) + len(v.Writers)
+	return nil
+}
+
+func (w *ManifestWriter) WriteIPs(v schema.IPs) error {
+	w.manifest.Counts["ips"] += len(v.IPs)
+	return nil
+}
+
+func (w *ManifestWriter) WriteLists(v schema.Lists) error {
+	w.manifest.Counts["lists"] += len(v.Lists)
+	return nil
+}
+
+func (w *ManifestWriter) WriteFollowedPublications(v schema.Publications) error {
+	w.manifest.Counts["following/publications"] += len(v.Publications)
+	return nil
+}
+
+func (w *ManifestWriter) WriteFollowedTopics(v schema.Topics) error {
+	w.manifest.Counts["following/topics"] += len(v.Topics)
+	return nil
+}
+
+func (w *ManifestWriter) WriteFollowedUsers(v schema.Users) error {
+	w.manifest.Counts["following/users"] += len(v.Users)
+	return nil
+}
+
+func (w *ManifestWriter) WriteSuggestedUsers(v schema.Users) error {
+	w.manifest.Counts["following/suggested"] += len(v.Users)
+	return nil
+}
+
+func (w *ManifestWriter) WriteSessions(v schema.Sessions) error {
+	w.manifest.Counts["sessions"] += len(v.Sessions)
+	return nil
+}
+
+func (w *ManifestWriter) WriteProfile(v schema.Profile) error {
+	w.manifest.Counts["profile"]++
+	return nil
+}
+
+func (w *ManifestWriter) Close() error {
+	err := os.MkdirAll(w.root, os.ModePerm)
+	if err != nil {
+		w.logger.Printf("can't create %s", w.root)
+		return err
+	}
+
+	for _, fp := range takeOutputs(w.root) {
+		file, err := checksum(filepath.Join(w.root, filepath.FromSlash(fp)))
+		if err != nil {
+			w.logger.Printf("can't checksum %s", fp)
+			return err
+		}
+
+		file.Path = fp
+		w.manifest.Files = append(w.manifest.Files, file)
+	}
+
+	w.manifest.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	out, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		w.logger.Printf("can't marshal manifest")
+		return err
+	}
+
+	err = writeFile(w.root, "manifest.json", out)
+	if err != nil {
+		w.logger.Printf("can't write manifest.json")
+		return err
+	}
+
+	return nil
+}
+
+func checksum(fp string) (schema.ManifestFile, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return schema.ManifestFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return schema.ManifestFile{}, err
+	}
+
+	return schema.ManifestFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,100 @@
+package formatters_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestManifestWriter(t *testing.T) {
+	root := t.TempDir()
+	logger := log.New(io.Discard, "", 0)
+
+	// Files that were in the output directory before the run aren't listed
+	os.MkdirAll(filepath.Join(root, "themes"), os.ModePerm)
+	os.WriteFile(filepath.Join(root, "themes", "theme.toml"), []byte("name = 'owls'"), 0644)
+
+	// Neither are files recorded by a run that failed
+	os.WriteFile(filepath.Join(root, "stale.json"), []byte("{}"), 0644)
+	formatters.RecordOutput(root, "stale.json")
+	formatters.ForgetOutputs(root)
+
+	jw, err := formatters.New("json", root, formatters.Options{}, logger)
+	if err != nil {
+		t.Fatalf("can't create json formatter: %v", err)
+	}
+
+	w := formatters.NewMultiWriter(jw, formatters.NewManifestWriter(root, "0.3", map[string]any{"strict": true}, logger))
+	w.Begin()
+	w.WritePost("basic", schema.Post{Id: "70c5683f3778", Title: "oh, right", ExportedAt: "2022-04-05"})
+	w.WriteClaps(schema.Claps{Claps: []schema.Clap{{Amount: 1}, {Amount: 2}}})
+	w.End()
+
+	err = formatters.WriteReport(root, &schema.Report{}, logger)
+	if err != nil {
+		t.Fatalf("can't write report: %v", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("can't close writers: %v", err)
+	}
+
+	var m schema.Manifest
+	dat, _ := os.ReadFile(filepath.Join(root, "manifest.json"))
+	err = json.Unmarshal(dat, &m)
+	if err != nil {
+		t.Fatalf("can't read manifest.json: %v", err)
+	}
+
+	if m.Version != "0.3" || m.ExportedAt != "2022-04-05" || m.StartedAt == "" || m.FinishedAt == "" {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+
+	if m.Counts["posts"] != 1 || m.Counts["posts/stories"] != 1 || m.Counts["claps"] != 2 {
+		t.Errorf("unexpected counts: %v", m.Counts)
+	}
+
+	if m.Options["strict"] != true {
+		t.Errorf("options are missing: %v", m.Options)
+	}
+
+	post, _ := os.ReadFile(filepath.Join(root, "posts", "stories", "basic.json"))
+	sum := sha256.Sum256(post)
+
+	want := []schema.ManifestFile{
+		{Path: "claps.json", Size: fileSize(t, root, "claps.json")},
+		{Path: "posts/stories/basic.json", Size: int64(len(post)), SHA256: hex.EncodeToString(sum[:])},
+		{Path: "posts/stories/index.json", Size: fileSize(t, root, "posts/stories/index.json")},
+	}
+
+	if len(m.Files) != len(want) {
+		t.Fatalf("want %d files; have %+v", len(want), m.Files)
+	}
+
+	for i, f := range m.Files {
+		if f.Path != want[i].Path || f.Size != want[i].Size || len(f.SHA256) != 64 {
+			t.Errorf("file %d: want %+v; have %+v", i, want[i], f)
+		}
+	}
+
+	if m.Files[1].SHA256 != want[1].SHA256 {
+		t.Errorf("wrong checksum for %s: %s", m.Files[1].Path, m.Files[1].SHA256)
+	}
+}
+
+func fileSize(t *testing.T, root, fp string) int64 {
+	info, err := os.Stat(filepath.Join(root, fp))
+	if err != nil {
+		t.Fatalf("%s wasn't written: %v", fp, err)
+	}
+	return info.Size()
+}
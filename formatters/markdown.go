@@ -0,0 +1,242 @@
+package formatters
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/valueof/meh/schema"
+)
+
+// MarkdownFormatter converts posts into Markdown files, one .md file per
+// post. All other export data is passed along to JSONFormatter so that
+// the output directory still contains the complete archive.
+type MarkdownFormatter struct {
+	jsonFallback
+	logger *log.Logger
+	root   string
+}
+
+func NewMarkdownFormatter(root string, logger *log.Logger) *MarkdownFormatter {
+	return &MarkdownFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+	}
+}
+
+func (w *MarkdownFormatter) WriteFile(fp string, v any) error {
+	post, ok := v.(schema.Post)
+	if !ok {
+		return w.fallback(fp, v)
+	}
+
+	err := writeOutput(w.root, fp+".md", []byte(markdownPost(post)))
+	if err != nil {
+		w.logger.Printf("can't write %s.md: %v", fp, err)
+		return err
+	}
+
+	return nil
+}
+
+// markdownPost renders post content as Markdown. Medium separates sections
+// with a horizontal rule so we do the same.
+func markdownPost(post schema.Post) string {
+	sections := []string{}
+	for _, s := range post.Content {
+		blocks := []string{}
+		for _, inner := range s.Body {
+			for _, g := range inner.Body {
+				if b := markdownGraf(g); b != "" {
+					blocks = append(blocks, b)
+				}
+			}
+		}
+
+		if len(blocks) > 0 {
+			sections = append(sections, strings.Join(blocks, "\n\n"))
+		}
+	}
+
+	if len(sections) == 0 {
+		return ""
+	}
+
+	return strings.Join(sections, "\n\n---\n\n") + "\n"
+}
+
+func markdownGraf(g schema.Graf) string {
+	switch g.Type {
+	case schema.H1:
+		return "# " + markdownInline(g.Text, g.Markups, " ")
+	case schema.H2:
+		return "## " + markdownInline(g.Text, g.Markups, " ")
+	case schema.H3:
+		return "### " + markdownInline(g.Text, g.Markups, " ")
+	case schema.H4:
+		return "#### " + markdownInline(g.Text, g.Markups, " ")
+	case schema.EMBED:
+		if g.Embed != nil {
+			return markdownEmbed(g)
+		}
+		return markdownEscapeLineStart(markdownInline(g.Text, g.Markups, "\\\n"))
+	case schema.P:
+		return markdownEscapeLineStart(markdownInline(g.Text, g.Markups, "\\\n"))
+	case schema.BLOCKQUOTE:
+		text := markdownInline(g.Text, g.Markups, "\\\n")
+		return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+	case schema.PRE:
+		code := strings.TrimRight(g.Text, "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return fence + "\n" + code + "\n" + fence
+	case schema.IMG:
+		images := []string{}
+		for _, img := range grafImages(g) {
+			images = append(images, fmt.Sprintf("![%s](%s)", markdownEscape(img.Alt), markdownURL(imageSource(img))))
+		}
+		if len(images) == 0 {
+			return ""
+		}
+		if g.Text != "" {
+			return strings.Join(images, "\n") + "\n\n" + markdownEscapeLineStart(markdownInline(g.Text, g.Markups, "\\\n"))
+		}
+		return strings.Join(images, "\n")
+	case schema.OL, schema.UL:
+		items := []string{}
+		for i, item := range g.Items {
+			marker := "- "
+			if g.Type == schema.OL {
+				marker = fmt.Sprintf("%d. ", i+1)
+			}
+			indent := "\n" + strings.Repeat(" ", len(marker))
+			items = append(items, marker+strings.ReplaceAll(markdownInline(item.Text, item.Markups, "\\\n"), "\n", indent))
+		}
+		return strings.Join(items, "\n")
+	case schema.HR:
+		return "---"
+	}
+
+	return ""
+}
+
+// markdownEmbed renders an embed as a link followed by its description or
+// caption. Link cards repeat their title and description in graf text so
+// it's only used for embeds without a title.
+func markdownEmbed(g schema.Graf) string {
+	e := g.Embed
+	b := fmt.Sprintf("[%s](%s)", markdownEscape(embedLabel(e)), markdownURL(e.Source))
+	if e.Description != "" {
+		b += "\\\n" + markdownEscape(e.Description)
+	}
+	if e.Title == "" && g.Text != "" {
+		b += "\\\n" + markdownInline(g.Text, g.Markups, "\\\n")
+	}
+	return b
+}
+
+// markdownInline renders graf text with its markups. Line breaks are
+// replaced with br, since not every block can span multiple lines.
+func markdownInline(text string, markups []schema.Markup, br string) string {
+	var b strings.Builder
+
+	for _, t := range hoistSpace(tokenize(text, markups)) {
+		switch t.kind {
+		case tokenText:
+			b.WriteString(markdownEscape(t.text))
+		case tokenBreak:
+			b.WriteString(br)
+		case tokenOpen:
+			switch t.markup.Type {
+			case schema.EM:
+				b.WriteString("*")
+			case schema.STRONG:
+				b.WriteString("**")
+			case schema.A:
+				b.WriteString("[")
+			case schema.HIGHLIGHT:
+				b.WriteString("<mark>")
+			}
+		case tokenClose:
+			switch t.markup.Type {
+			case schema.EM:
+				b.WriteString("*")
+			case schema.STRONG:
+				b.WriteString("**")
+			case schema.A:
+				b.WriteString("](" + markdownURL(t.markup.Href) + ")")
+			case schema.HIGHLIGHT:
+				b.WriteString("</mark>")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+)
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// markdownEscapeLineStart escapes characters that would turn a paragraph
+// into a heading, a list or a quote.
+func markdownEscapeLineStart(s string) string {
+	if s == "" {
+		return s
+	}
+
+	switch s[0] {
+	case '#', '>', '-', '+', '=':
+		return `\` + s
+	}
+
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+
+	if i > 0 && i < len(s) && (s[i] == '.' || s[i] == ')') {
+		return s[:i] + `\` + s[i:]
+	}
+
+	return s
+}
+
+func markdownURL(s string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(s)
+}
+
+// grafImages returns all images of a figure. Grafs built by hand may
+// only have Image set.
+func grafImages(g schema.Graf) []*schema.Image {
+	images := []*schema.Image{}
+	for i := range g.Images {
+		images = append(images, &g.Images[i])
+	}
+	if len(images) == 0 && g.Image != nil {
+		images = append(images, g.Image)
+	}
+	return images
+}
+
+// imageSource returns a URL for an image, falling back to Medium CDN
+// when the export didn't include one.
+func imageSource(img *schema.Image) string {
+	if img.Source != "" {
+		return img.Source
+	}
+	return "https://cdn-images-1.medium.com/" + img.Name
+}
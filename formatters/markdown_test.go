@@ -0,0 +1,158 @@
+package formatters_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestMarkdownFormatter(t *testing.T) {
+	tests := []struct {
+		graf schema.Graf
+		want string
+	}{
+		{
+			graf: schema.Graf{Type: schema.H3, Text: "The owls"},
+			want: "### The owls\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.P,
+				Text: "The owls are not what they seem",
+				Markups: []schema.Markup{
+					{Type: schema.EM, Start: 4, End: 8},
+					{Type: schema.STRONG, Start: 22, End: 31},
+					{Type: schema.EM, Start: 22, End: 31},
+				},
+			},
+			want: "The *owls* are not what ***they seem***\n",
+		},
+		{
+			// Overlapping spans are split to keep the output well-formed
+			graf: schema.Graf{
+				Type: schema.P,
+				Text: "The owls are not",
+				Markups: []schema.Markup{
+					{Type: schema.EM, Start: 4, End: 12},
+					{Type: schema.STRONG, Start: 9, End: 16},
+				},
+			},
+			want: "The *owls **are*** **not**\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.P,
+				Text: "The owls are not",
+				Markups: []schema.Markup{
+					{Type: schema.A, Start: 4, End: 9, Href: "https://owls.com"},
+					{Type: schema.HIGHLIGHT, Start: 13, End: 16},
+				},
+			},
+			want: "The [owls](https://owls.com) are <mark>not</mark>\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.BLOCKQUOTE,
+				Text: "onetwo",
+				Markups: []schema.Markup{
+					{Type: schema.BR, Start: 3, End: 3},
+				},
+			},
+			want: "> one\\\n> two\n",
+		},
+		{
+			graf: schema.Graf{Type: schema.PRE, Text: "x := 1\n"},
+			want: "```\nx := 1\n```\n",
+		},
+		{
+			graf: schema.Graf{Type: schema.IMG, Image: &schema.Image{Name: "owl.png"}},
+			want: "![](https://cdn-images-1.medium.com/owl.png)\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.OL,
+				Items: []schema.Graf{
+					{Type: schema.LI, Text: "Great horned owl", Markups: []schema.Markup{{Type: schema.EM, Start: 6, End: 12}}},
+					{Type: schema.LI, Text: "Barredowl", Markups: []schema.Markup{{Type: schema.BR, Start: 6, End: 6}}},
+				},
+			},
+			want: "1. Great *horned* owl\n2. Barred\\\n   owl\n",
+		},
+		{
+			graf: schema.Graf{
+				Type:  schema.UL,
+				Items: []schema.Graf{{Type: schema.LI, Text: "Herons"}, {Type: schema.LI, Text: "Woodpeckers"}},
+			},
+			want: "- Herons\n- Woodpeckers\n",
+		},
+		{
+			graf: schema.Graf{
+				Type:   schema.IMG,
+				Text:   "Feeder regulars",
+				Layout: schema.GRID,
+				Images: []schema.Image{{Name: "finch.png", Alt: "Finch"}, {Name: "wren.png", Alt: "Wren"}},
+			},
+			want: "![Finch](https://cdn-images-1.medium.com/finch.png)\n![Wren](https://cdn-images-1.medium.com/wren.png)\n\nFeeder regulars\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.EMBED,
+				Text: "Barred owls",
+				Embed: &schema.Embed{
+					Provider: schema.YOUTUBE,
+					Source:   "https://www.youtube.com/watch?v=ifFUn9mAgVQ",
+				},
+			},
+			want: "[https://www.youtube.com/watch?v=ifFUn9mAgVQ](https://www.youtube.com/watch?v=ifFUn9mAgVQ)\\\nBarred owls\n",
+		},
+		{
+			graf: schema.Graf{
+				Type: schema.EMBED,
+				Text: "oh, rightThe owlsmedium.com",
+				Embed: &schema.Embed{
+					Provider:    schema.LINK,
+					Source:      "https://medium.com/@anton/oh-right-70c5683f3778",
+					Title:       "oh, right",
+					Description: "The owls",
+				},
+			},
+			want: "[oh, right](https://medium.com/@anton/oh-right-70c5683f3778)\\\nThe owls\n",
+		},
+		{
+			graf: schema.Graf{Type: schema.P, Text: "1. *not* a list"},
+			want: "1\\. \\*not\\* a list\n",
+		},
+	}
+
+	root := t.TempDir()
+	w := formatters.NewMarkdownFormatter(root, log.New(os.Stdout, "", 0))
+
+	for n, tt := range tests {
+		post := schema.Post{
+			Content: []schema.Section{
+				{Body: []schema.InnerSection{{Body: []schema.Graf{tt.graf}}}},
+			},
+		}
+
+		err := w.WriteFile(filepath.Join("posts", "test"), post)
+		if err != nil {
+			t.Errorf("test %d failed: %v", n, err)
+			continue
+		}
+
+		have, err := os.ReadFile(filepath.Join(root, "posts", "test.md"))
+		if err != nil {
+			t.Errorf("test %d failed: %v", n, err)
+			continue
+		}
+
+		if string(have) != tt.want {
+			t.Errorf("test %d failed", n)
+			t.Errorf("want: %q; have: %q", tt.want, have)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package formatters
+
+import "github.com/valueof/meh/schema"
+
+// MultiWriter sends export data to several writers at once so an archive
+// can be converted into multiple formats in one parser pass. All writers
+// share the same output directory.
+//
+// Every call is passed to all writers, even if some of them fail, and
+// returns the first error.
+type MultiWriter struct {
+	writers []Writer
+}
+
+func NewMultiWriter(ws ...Writer) *MultiWriter {
+	return &MultiWriter{writers: ws}
+}
+
+func (m *MultiWriter) each(fn func(Writer) error) error {
+	var first error
+	for _, w := range m.writers {
+		err := fn(w)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (m *MultiWriter) Begin() error {
+	return m.each(func(w Writer) error { return w.Begin() })
+}
+
+func (m *MultiWriter) WritePost(name string, post schema.Post) error {
+	return m.each(func(w Writer) error { return w.WritePost(name, post) })
+}
+
+func (m *MultiWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	return m.each(func(w Writer) error { return w.WriteBlockedUsers(v) })
+}
+
+func (m *MultiWriter) WriteBookmarks(v schema.Bookmarks) error {
+	return m.each(func(w Writer) error { return w.WriteBookmarks(v) })
+}
+
+func (m *MultiWriter) WriteClaps(v schema.Claps) error {
+	return m.each(func(w Writer) error { return w.WriteClaps(v) })
+}
+
+func (m *MultiWriter) WriteHighlights(v schema.Highlights) error {
+	return m.each(func(w Writer) error { return w.WriteHighlights(v) })
+}
+
+func (m *MultiWriter) WriteInterests(v schema.Interests) error {
+	return m.each(func(w Writer) error { return w.WriteInterests(v) })
+}
+
+func (m *MultiWriter) WriteIPs(v schema.IPs) error {
+	return m.each(func(w Writer) error { return w.WriteIPs(v) })
+}
+
+func (m *MultiWriter) WriteLists(v schema.Lists) error {
+	return m.each(func(w Writer) error { return w.WriteLists(v) })
+}
+
+func (m *MultiWriter) WriteFollowedPublications(v schema.Publications) error {
+	return m.each(func(w Writer) error { return w.WriteFollowedPublications(v) })
+}
+
+func (m *MultiWriter) WriteFollowedTopics(v schema.Topics) error {
+	return m.each(func(w Writer) error { return w.WriteFollowedTopics(v) })
+}
+
+func (m *MultiWriter) WriteFollowedUsers(v schema.Users) error {
+	return m.each(func(w Writer) error { return w.WriteFollowedUsers(v) })
+}
+
+func (m *MultiWriter) WriteSuggestedUsers(v schema.Users) error {
+	return m.each(func(w Writer) error { return w.WriteSuggestedUsers(v) })
+}
+
+func (m *MultiWriter) WriteSessions(v schema.Sessions) error {
+	return m.each(func(w Writer) error { return w.WriteSessions(v) })
+}
+
+func (m *MultiWriter) WriteProfile(v schema.Profile) error {
+	return m.each(func(w Writer) error { return w.WriteProfile(v) })
+}
+
+func (m *MultiWriter) End() error {
+	return m.each(func(w Writer) error { return w.End() })
+}
+
+func (m *MultiWriter) Close() error {
+	return m.each(func(w Writer) error { return w.Close() })
+}
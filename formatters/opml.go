@@ -0,0 +1,186 @@
+package formatters
+
+import (
+	"bytes"
+	"html"
+	"log"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+var opmlTemplate = template.Must(template.New("opml").Funcs(template.FuncMap{
+	"esc": html.EscapeString,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head>
+    <title>{{esc .Title}}</title>
+    <dateCreated>{{.DateCreated}}</dateCreated>{{if .Owner}}
+    <ownerName>{{esc .Owner}}</ownerName>{{end}}
+  </head>
+  <body>{{range .Groups}}
+    <outline text="{{esc .Name}}" title="{{esc .Name}}">{{range .Feeds}}
+      <outline type="rss" text="{{esc .Name}}" title="{{esc .Name}}" xmlUrl="{{esc .XmlUrl}}" htmlUrl="{{esc .HtmlUrl}}"/>{{end}}
+    </outline>{{end}}
+  </body>
+</opml>
+`))
+
+type opmlFeed struct {
+	Name    string
+	XmlUrl  string
+	HtmlUrl string
+}
+
+type opmlGroup struct {
+	Name  string
+	Feeds []opmlFeed
+}
+
+// OPMLFormatter writes everything a user follows on Medium into an OPML
+// 2.0 file that can be imported into feed readers (Feedly, NetNewsWire,
+// Miniflux, etc.) Publications, topics, writers and Twitter suggestions
+// go into separate groups, each entry points to its Medium RSS feed.
+// Publications and writers from interests are merged into the same groups
+// as the ones being followed. All export data is also passed along to
+// JSONFormatter.
+//
+// OPMLFormatter writes following.opml on Close.
+type OPMLFormatter struct {
+	jsonFallback
+	logger  *log.Logger
+	root    string
+	groups  map[string][]opmlFeed
+	profile *schema.Profile
+}
+
+// Names of outline groups, in the order they appear in the file
+var opmlGroups = []string{"Publications", "Topics", "Writers", "Twitter suggestions"}
+
+func NewOPMLFormatter(root string, logger *log.Logger) *OPMLFormatter {
+	return &OPMLFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		groups:       map[string][]opmlFeed{},
+	}
+}
+
+func (w *OPMLFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Publications:
+		for _, p := range v.Publications {
+			w.add("Publications", p.Name, p.Url)
+		}
+	case schema.Topics:
+		for _, t := range v.Topics {
+			w.add("Topics", t.Name, t.Url)
+		}
+	case schema.Users:
+		group := "Writers"
+		if filepath.Base(fp) == "suggested" {
+			group = "Twitter suggestions"
+		}
+		for _, u := range v.Users {
+			w.addUser(group, u)
+		}
+	case schema.Interests:
+		for _, p := range v.Publications {
+			w.add("Publications", p.Name, p.Url)
+		}
+		for _, t := range v.Topics {
+			w.add("Topics", t.Name, t.Url)
+		}
+		for _, t := range v.Tags {
+			w.add("Topics", t.Name, t.Url)
+		}
+		for _, u := range v.Writers {
+			w.addUser("Writers", u)
+		}
+	case schema.Profile:
+		w.profile = &v
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close writes following.opml
+func (w *OPMLFormatter) Close() error {
+	doc := struct {
+		Title       string
+		DateCreated string
+		Owner       string
+		Groups      []opmlGroup
+	}{
+		Title:       "Medium subscriptions",
+		DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		Groups:      []opmlGroup{},
+	}
+
+	if w.profile != nil && w.profile.User != nil {
+		doc.Owner = w.profile.User.Name
+		if doc.Owner == "" {
+			doc.Owner = w.profile.User.Username
+		}
+	}
+
+	for _, name := range opmlGroups {
+		if len(w.groups[name]) > 0 {
+			doc.Groups = append(doc.Groups, opmlGroup{Name: name, Feeds: w.groups[name]})
+		}
+	}
+
+	var b bytes.Buffer
+	err := opmlTemplate.Execute(&b, doc)
+	if err != nil {
+		w.logger.Printf("can't render following.opml: %v", err)
+		return err
+	}
+
+	err = writeOutput(w.root, "following.opml", b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write following.opml: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *OPMLFormatter) addUser(group string, u schema.User) {
+	url := u.Url
+	if url == "" && u.Username != "" {
+		url = "https://medium.com/@" + u.Username
+	}
+
+	name := u.Name
+	if name == "" {
+		name = u.Username
+	}
+
+	w.add(group, name, url)
+}
+
+// add appends a feed to a group unless it's already there or there's no
+// way to build a feed URL for it.
+func (w *OPMLFormatter) add(group, name, url string) {
+	feed := util.MediumFeedUrl(url)
+	if feed == "" {
+		w.logger.Printf("can't build a feed URL for %s, skipping", url)
+		return
+	}
+
+	for _, f := range w.groups[group] {
+		if f.XmlUrl == feed {
+			return
+		}
+	}
+
+	if name == "" {
+		name = url
+	}
+
+	w.groups[group] = append(w.groups[group], opmlFeed{Name: name, XmlUrl: feed, HtmlUrl: url})
+}
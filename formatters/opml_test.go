@@ -0,0 +1,83 @@
+package formatters_test
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestOPMLFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewOPMLFormatter(root, log.New(io.Discard, "", 0))
+
+	w.WriteFile("following/publications", schema.Publications{Publications: []schema.Publication{
+		{Name: "Wildlife Trekker", Url: "https://medium.com/wildlife-trekker"},
+	}})
+	w.WriteFile("following/users", schema.Users{Users: []schema.User{
+		{Username: "dpup", Url: "https://medium.com/@dpup"},
+	}})
+	w.WriteFile("following/suggested", schema.Users{Users: []schema.User{
+		{Username: "anton"},
+	}})
+	w.WriteFile("interests", schema.Interests{
+		Publications: []schema.Publication{{Name: "3 min read", Url: "https://blog.medium.com"}},
+		Tags:         []schema.Tag{{Name: "Birds", Url: "https://medium.com/tag/birds"}},
+		Writers:      []schema.User{{Name: "Dan Pupius", Username: "dpup", Url: "https://medium.com/@dpup"}},
+	})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	dat, err := os.ReadFile(filepath.Join(root, "following.opml"))
+	if err != nil {
+		t.Fatalf("can't read following.opml: %v", err)
+	}
+
+	type outline struct {
+		Text     string    `xml:"text,attr"`
+		XmlUrl   string    `xml:"xmlUrl,attr"`
+		Outlines []outline `xml:"outline"`
+	}
+
+	var opml struct {
+		Outlines []outline `xml:"body>outline"`
+	}
+
+	err = xml.Unmarshal(dat, &opml)
+	if err != nil {
+		t.Fatalf("following.opml is not valid XML: %v", err)
+	}
+
+	want := map[string][]string{
+		"Publications":        {"https://medium.com/feed/wildlife-trekker", "https://blog.medium.com/feed"},
+		"Topics":              {"https://medium.com/feed/tag/birds"},
+		"Writers":             {"https://medium.com/feed/@dpup"},
+		"Twitter suggestions": {"https://medium.com/feed/@anton"},
+	}
+
+	if len(opml.Outlines) != len(want) {
+		t.Fatalf("want %d groups; have %d", len(want), len(opml.Outlines))
+	}
+
+	for _, group := range opml.Outlines {
+		feeds := want[group.Text]
+		if len(group.Outlines) != len(feeds) {
+			t.Errorf("%s: want %d feeds; have %d", group.Text, len(feeds), len(group.Outlines))
+			continue
+		}
+
+		for i, f := range group.Outlines {
+			if f.XmlUrl != feeds[i] {
+				t.Errorf("%s: want %s; have %s", group.Text, feeds[i], f.XmlUrl)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package formatters
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Options configure formatters created with New. Formatters ignore
+// options that don't apply to them.
+type Options struct {
+	// FrontMatter overrides front matter format of static site
+	// generators (hugo, jekyll, eleventy)
+	FrontMatter FrontMatter
+
+	// BaseURL is where post links in feeds should point to
+	BaseURL string
+
+	// WithClaps adds clapped posts to bookmark exports
+	WithClaps bool
+}
+
+// Factory creates a writer that writes into root
+type Factory func(root string, opts Options, logger *log.Logger) (Writer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	site := func(profile SiteProfile) Factory {
+		return func(root string, opts Options, logger *log.Logger) (Writer, error) {
+			switch opts.FrontMatter {
+			case "":
+			case YAML, TOML:
+				profile.FrontMatter = opts.FrontMatter
+			default:
+				return nil, fmt.Errorf("unknown front matter format: %s", opts.FrontMatter)
+			}
+			return NewWriter(NewSiteFormatter(root, profile, logger)), nil
+		}
+	}
+
+	Register("json", simple(NewJSONFormatter))
+	Register("jsonl", simple(NewJSONLFormatter))
+	Register("markdown", simple(NewMarkdownFormatter))
+	Register("html", simple(NewHTMLFormatter))
+	Register("epub", simple(NewEPUBFormatter))
+	Register("opml", simple(NewOPMLFormatter))
+	Register("wordpress", simple(NewWXRFormatter))
+	Register("ghost", simple(NewGhostFormatter))
+	Register("csv", simple(NewCSVFormatter))
+	Register("tsv", simple(NewTSVFormatter))
+	Register("hugo", site(HugoProfile))
+	Register("jekyll", site(JekyllProfile))
+	Register("eleventy", site(EleventyProfile))
+
+	Register("sqlite", func(root string, opts Options, logger *log.Logger) (Writer, error) {
+		return NewSQLiteWriter(root, logger), nil
+	})
+
+	Register("feed", func(root string, opts Options, logger *log.Logger) (Writer, error) {
+		return NewWriter(NewFeedFormatter(root, opts.BaseURL, logger)), nil
+	})
+
+	Register("bookmarks", func(root string, opts Options, logger *log.Logger) (Writer, error) {
+		return NewWriter(NewBookmarksFormatter(root, opts.WithClaps, logger)), nil
+	})
+}
+
+// simple turns a formatter constructor that doesn't take any options
+// into a Factory
+func simple[F Formatter](f func(string, *log.Logger) F) Factory {
+	return func(root string, opts Options, logger *log.Logger) (Writer, error) {
+		return NewWriter(f(root, logger)), nil
+	}
+}
+
+// Register makes a format available by name. It panics if a format
+// with the same name was already registered.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("formatters: Register called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// Names returns names of all registered formats, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a writer registered under name
+func New(name, root string, opts Options, logger *log.Logger) (Writer, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %s", name)
+	}
+
+	return f(root, opts, logger)
+}
+
+// NewMulti creates writers for all names and combines them into one.
+// A single name returns its writer as is.
+//
+// Formats that pass data they don't convert along to JSON share one
+// JSONFormatter, so every JSON file is written once. If json is one of
+// the formats, it writes them all and the others don't write JSON at all.
+func NewMulti(names []string, root string, opts Options, logger *log.Logger) (Writer, error) {
+	ws := []Writer{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		w, err := New(name, root, opts, logger)
+		if err != nil {
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+
+	if len(ws) == 1 {
+		return ws[0], nil
+	}
+
+	var shared Formatter
+	if !seen["json"] {
+		shared = newSharedJSON(root, logger)
+	}
+
+	for _, w := range ws {
+		if fw, ok := w.(*formatterWriter); ok {
+			if f, ok := fw.f.(interface{ setFallback(Formatter) }); ok {
+				f.setFallback(shared)
+			}
+		}
+	}
+
+	return NewMultiWriter(ws...), nil
+}
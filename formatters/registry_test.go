@@ -0,0 +1,104 @@
+package formatters_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestNew(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	for _, name := range formatters.Names() {
+		_, err := formatters.New(name, t.TempDir(), formatters.Options{}, logger)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	_, err := formatters.New("bogus", t.TempDir(), formatters.Options{}, logger)
+	if err == nil {
+		t.Errorf("want an error for an unknown format")
+	}
+
+	_, err = formatters.New("hugo", t.TempDir(), formatters.Options{FrontMatter: "json"}, logger)
+	if err == nil {
+		t.Errorf("want an error for an unknown front matter format")
+	}
+}
+
+func TestNewMulti(t *testing.T) {
+	root := t.TempDir()
+	w, err := formatters.NewMulti([]string{"json", "markdown", "csv", "json"}, root, formatters.Options{}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("can't create formatters: %v", err)
+	}
+
+	w.Begin()
+	w.WritePost("basic", schema.Post{Id: "70c5683f3778", Title: "oh, right"})
+	w.WriteIPs(schema.IPs{IPs: []schema.IP{{Address: "127.0.0.1"}}})
+	w.End()
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("can't close formatters: %v", err)
+	}
+
+	for _, fp := range []string{"posts/stories/basic.json", "posts/stories/basic.md", "posts/stories/index.json", "posts.csv", "ips.json", "ips.csv"} {
+		_, err := os.Stat(filepath.Join(root, fp))
+		if err != nil {
+			t.Errorf("%s wasn't written: %v", fp, err)
+		}
+	}
+}
+
+func TestNewMultiSharedJSON(t *testing.T) {
+	root := t.TempDir()
+	w, err := formatters.NewMulti([]string{"markdown", "html", "opml"}, root, formatters.Options{}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("can't create formatters: %v", err)
+	}
+
+	w.Begin()
+	w.WritePost("basic", schema.Post{Id: "70c5683f3778", Title: "oh, right"})
+	w.WriteIPs(schema.IPs{IPs: []schema.IP{{Address: "127.0.0.1"}}})
+	w.End()
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("can't close formatters: %v", err)
+	}
+
+	// opml passes posts along to JSON, markdown and html don't, but all of
+	// them share the same files
+	for _, fp := range []string{"ips.json", "posts/stories/index.json", "posts/stories/basic.json", "posts/stories/basic.md", "posts/stories/basic.html"} {
+		_, err := os.Stat(filepath.Join(root, fp))
+		if err != nil {
+			t.Errorf("%s wasn't written: %v", fp, err)
+		}
+	}
+
+	root = t.TempDir()
+	w, err = formatters.NewMulti([]string{"markdown", "html"}, root, formatters.Options{}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("can't create formatters: %v", err)
+	}
+
+	w.Begin()
+	w.WritePost("basic", schema.Post{Id: "70c5683f3778", Title: "oh, right"})
+	w.End()
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("can't close formatters: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "posts/stories/basic.json")); err == nil {
+		t.Errorf("posts shouldn't be written as JSON by markdown and html")
+	}
+}
@@ -0,0 +1,27 @@
+package formatters
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/valueof/meh/schema"
+)
+
+// WriteReport saves a parse report into root as report.json, no matter
+// which formats were picked. The report describes the run so it's not
+// listed in the manifest.
+func WriteReport(root string, r *schema.Report, logger *log.Logger) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		logger.Printf("can't marshal report")
+		return err
+	}
+
+	err = writeFile(root, "report.json", out)
+	if err != nil {
+		logger.Printf("can't write report.json: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package formatters
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+type FrontMatter string
+
+const (
+	YAML FrontMatter = "yaml"
+	TOML FrontMatter = "toml"
+)
+
+// SiteProfile describes where a static site generator expects to find
+// content files and how it wants them to be named.
+type SiteProfile struct {
+	Name        string
+	PostsDir    string
+	DraftsDir   string
+	DataDir     string
+	DatePrefix  bool
+	FrontMatter FrontMatter
+}
+
+var (
+	HugoProfile = SiteProfile{
+		Name:        "hugo",
+		PostsDir:    "content/posts",
+		DraftsDir:   "content/posts",
+		DataDir:     "data",
+		FrontMatter: TOML,
+	}
+
+	JekyllProfile = SiteProfile{
+		Name:        "jekyll",
+		PostsDir:    "_posts",
+		DraftsDir:   "_drafts",
+		DataDir:     "_data",
+		DatePrefix:  true,
+		FrontMatter: YAML,
+	}
+
+	EleventyProfile = SiteProfile{
+		Name:        "eleventy",
+		PostsDir:    "posts",
+		DraftsDir:   "posts",
+		DataDir:     "_data",
+		FrontMatter: YAML,
+	}
+)
+
+// SiteFormatter converts posts into Markdown files with front matter that
+// static site generators (Hugo, Jekyll, Eleventy) understand. All other
+// export data is written as JSON into the generator's data directory.
+type SiteFormatter struct {
+	logger  *log.Logger
+	root    string
+	profile SiteProfile
+	json    *JSONFormatter
+	names   map[string]bool
+}
+
+func NewSiteFormatter(root string, profile SiteProfile, logger *log.Logger) *SiteFormatter {
+	return &SiteFormatter{
+		logger:  logger,
+		root:    root,
+		profile: profile,
+		json:    NewJSONFormatter(filepath.Join(root, profile.DataDir), logger),
+		names:   map[string]bool{},
+	}
+}
+
+func (w *SiteFormatter) WriteFile(fp string, v any) error {
+	post, ok := v.(schema.Post)
+	if !ok {
+		return w.json.WriteFile(fp, v)
+	}
+
+	date, err := time.Parse(time.RFC3339, post.PublishedAt)
+	published := err == nil
+
+	slug := util.ParseMediumSlug(post.Url)
+	if slug == "" {
+		slug = filepath.Base(fp)
+	}
+
+	name := slug
+	if w.names[name] && post.Id != "" {
+		name = slug + "-" + post.Id
+	}
+	w.names[name] = true
+
+	dir := w.profile.DraftsDir
+	if published {
+		dir = w.profile.PostsDir
+		if w.profile.DatePrefix {
+			name = date.Format("2006-01-02") + "-" + name
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(w.frontMatter(post, slug, published))
+	b.WriteString("\n")
+	b.WriteString(markdownPost(stripTitle(post)))
+
+	dest := filepath.Join(dir, name+".md")
+	err = writeOutput(w.root, dest, []byte(b.String()))
+	if err != nil {
+		w.logger.Printf("can't write %s: %v", dest, err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *SiteFormatter) frontMatter(post schema.Post, slug string, published bool) string {
+	images := []string{}
+	forEachGraf(post, func(g schema.Graf) {
+		for _, img := range grafImages(g) {
+			images = append(images, imageSource(img))
+		}
+	})
+
+	type field struct {
+		key   string
+		value string
+	}
+
+	fields := []field{
+		{"title", quoteString(post.Title)},
+	}
+
+	if post.Subtitle != "" {
+		fields = append(fields, field{"subtitle", quoteString(post.Subtitle)})
+	}
+
+	if post.Author != nil {
+		author := post.Author.Name
+		if author == "" {
+			author = post.Author.Username
+		}
+		if author != "" {
+			fields = append(fields, field{"author", quoteString(author)})
+		}
+	}
+
+	if published {
+		fields = append(fields, field{"date", post.PublishedAt})
+	} else {
+		fields = append(fields, field{"draft", "true"})
+	}
+
+	fields = append(fields, field{"slug", quoteString(slug)})
+
+	if post.Url != "" {
+		fields = append(fields, field{"canonical_url", quoteString(post.Url)})
+	}
+
+	if post.Id != "" {
+		fields = append(fields, field{"medium_id", quoteString(post.Id)})
+	}
+
+	if post.Kind == schema.RESPONSE {
+		fields = append(fields, field{"response", "true"})
+		if post.InReplyTo != nil && post.InReplyTo.Url != "" {
+			fields = append(fields, field{"in_reply_to", quoteString(post.InReplyTo.Url)})
+		}
+	}
+
+	var b strings.Builder
+
+	switch w.profile.FrontMatter {
+	case TOML:
+		b.WriteString("+++\n")
+		for _, f := range fields {
+			fmt.Fprintf(&b, "%s = %s\n", f.key, f.value)
+		}
+
+		quoted := []string{}
+		for _, img := range images {
+			quoted = append(quoted, quoteString(img))
+		}
+		fmt.Fprintf(&b, "images = [%s]\n", strings.Join(quoted, ", "))
+		b.WriteString("+++\n")
+	default:
+		b.WriteString("---\n")
+		for _, f := range fields {
+			fmt.Fprintf(&b, "%s: %s\n", f.key, f.value)
+		}
+
+		if len(images) == 0 {
+			b.WriteString("images: []\n")
+		} else {
+			b.WriteString("images:\n")
+			for _, img := range images {
+				fmt.Fprintf(&b, "  - %s\n", quoteString(img))
+			}
+		}
+		b.WriteString("---\n")
+	}
+
+	return b.String()
+}
+
+// stripTitle removes the first graf of a post if it repeats the title.
+// Medium keeps the title inside the post body but static site generators
+// render it from front matter.
+func stripTitle(post schema.Post) schema.Post {
+	if len(post.Content) == 0 || len(post.Content[0].Body) == 0 {
+		return post
+	}
+
+	first := post.Content[0].Body[0]
+	if len(first.Body) == 0 || first.Body[0].Text != post.Title {
+		return post
+	}
+
+	inner := first
+	inner.Body = first.Body[1:]
+
+	section := post.Content[0]
+	section.Body = append([]schema.InnerSection{inner}, section.Body[1:]...)
+
+	post.Content = append([]schema.Section{section}, post.Content[1:]...)
+	return post
+}
+
+// quoteString returns a double-quoted string that is valid both in YAML
+// and TOML.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package formatters_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestSiteFormatter(t *testing.T) {
+	post := schema.Post{
+		Id:          "70c5683f3778",
+		Url:         "https://medium.com/@anton/oh-right-70c5683f3778",
+		Title:       "oh, \"right\"",
+		Subtitle:    "a poem",
+		Author:      &schema.User{Name: "Anton Kovalyov", Username: "anton"},
+		PublishedAt: "2015-02-05T02:56:45.739Z",
+		Content: []schema.Section{
+			{Body: []schema.InnerSection{{Body: []schema.Graf{
+				{Type: schema.H3, Text: "oh, \"right\""},
+				{Type: schema.P, Text: "it’s early evening"},
+			}}}},
+		},
+	}
+
+	tests := map[string]struct {
+		profile formatters.SiteProfile
+		want    string
+	}{
+		"content/posts/oh-right.md": {
+			profile: formatters.HugoProfile,
+			want: `+++
+title = "oh, \"right\""
+subtitle = "a poem"
+author = "Anton Kovalyov"
+date = 2015-02-05T02:56:45.739Z
+slug = "oh-right"
+canonical_url = "https://medium.com/@anton/oh-right-70c5683f3778"
+medium_id = "70c5683f3778"
+images = []
++++
+
+it’s early evening
+`,
+		},
+		"_posts/2015-02-05-oh-right.md": {
+			profile: formatters.JekyllProfile,
+			want: `---
+title: "oh, \"right\""
+subtitle: "a poem"
+author: "Anton Kovalyov"
+date: 2015-02-05T02:56:45.739Z
+slug: "oh-right"
+canonical_url: "https://medium.com/@anton/oh-right-70c5683f3778"
+medium_id: "70c5683f3778"
+images: []
+---
+
+it’s early evening
+`,
+		},
+	}
+
+	reply := post
+	reply.Url = "https://medium.com/@anton/nice-e0b4bd5f2c1a"
+	reply.Kind = schema.RESPONSE
+	reply.InReplyTo = &schema.Post{Url: "https://medium.com/@anton/owls-1234567890ab"}
+	reply.Subtitle = ""
+	reply.Author = &schema.User{Username: "anton"}
+
+	root := t.TempDir()
+	w := formatters.NewSiteFormatter(root, formatters.HugoProfile, log.New(os.Stdout, "", 0))
+	err := w.WriteFile(filepath.Join("posts", "reply"), reply)
+	if err != nil {
+		t.Fatalf("can't write a response: %v", err)
+	}
+
+	have, _ := os.ReadFile(filepath.Join(root, "content", "posts", "nice.md"))
+	if !strings.Contains(string(have), "response = true\nin_reply_to = \"https://medium.com/@anton/owls-1234567890ab\"\n") {
+		t.Errorf("response isn't marked in front matter:\n%s", have)
+	}
+
+	if strings.Contains(string(have), "subtitle") || !strings.Contains(string(have), "author = \"anton\"\n") {
+		t.Errorf("want no subtitle and username as author in front matter:\n%s", have)
+	}
+
+	for fp, tt := range tests {
+		root := t.TempDir()
+		w := formatters.NewSiteFormatter(root, tt.profile, log.New(os.Stdout, "", 0))
+
+		err := w.WriteFile(filepath.Join("posts", "basic"), post)
+		if err != nil {
+			t.Errorf("%s: %v", tt.profile.Name, err)
+			continue
+		}
+
+		have, err := os.ReadFile(filepath.Join(root, fp))
+		if err != nil {
+			t.Errorf("%s: %v", tt.profile.Name, err)
+			continue
+		}
+
+		if string(have) != tt.want {
+			t.Errorf("%s\nwant: %s\nhave: %s", tt.profile.Name, tt.want, have)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package formatters
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/valueof/meh/schema"
+)
+
+const sqliteSchema = `PRAGMA foreign_keys = ON;
+BEGIN TRANSACTION;
+
+CREATE TABLE posts (
+  id TEXT PRIMARY KEY,
+  url TEXT,
+  title TEXT,
+  subtitle TEXT,
+  author_username TEXT,
+  status TEXT,
+  kind TEXT,
+  published_at TEXT,
+  file TEXT
+);
+
+CREATE TABLE sections (
+  id INTEGER PRIMARY KEY,
+  post_id TEXT NOT NULL REFERENCES posts(id),
+  position INTEGER NOT NULL,
+  name TEXT
+);
+
+CREATE TABLE inner_sections (
+  id INTEGER PRIMARY KEY,
+  section_id INTEGER NOT NULL REFERENCES sections(id),
+  position INTEGER NOT NULL,
+  classes TEXT
+);
+
+CREATE TABLE grafs (
+  id INTEGER PRIMARY KEY,
+  inner_section_id INTEGER REFERENCES inner_sections(id),
+  highlight_id INTEGER REFERENCES highlights(id),
+  position INTEGER NOT NULL,
+  type TEXT NOT NULL,
+  name TEXT,
+  text TEXT,
+  image_name TEXT,
+  image_source TEXT,
+  image_width TEXT,
+  image_height TEXT,
+  parent_id INTEGER REFERENCES grafs(id),
+  embed_provider TEXT,
+  embed_source TEXT,
+  embed_title TEXT,
+  embed_description TEXT,
+  layout TEXT
+);
+
+CREATE TABLE images (
+  graf_id INTEGER NOT NULL REFERENCES grafs(id),
+  position INTEGER NOT NULL,
+  name TEXT,
+  source TEXT,
+  alt TEXT,
+  width TEXT,
+  height TEXT
+);
+
+CREATE TABLE markups (
+  id INTEGER PRIMARY KEY,
+  graf_id INTEGER NOT NULL REFERENCES grafs(id),
+  position INTEGER NOT NULL,
+  type TEXT NOT NULL,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  href TEXT
+);
+
+CREATE TABLE claps (
+  post_id TEXT NOT NULL REFERENCES posts(id),
+  amount INTEGER NOT NULL
+);
+
+CREATE TABLE bookmarks (
+  post_id TEXT NOT NULL REFERENCES posts(id),
+  position INTEGER NOT NULL
+);
+
+CREATE TABLE highlights (
+  id INTEGER PRIMARY KEY,
+  created_at TEXT,
+  text TEXT
+);
+
+CREATE TABLE lists (
+  id INTEGER PRIMARY KEY,
+  name TEXT,
+  summary TEXT
+);
+
+CREATE TABLE list_posts (
+  list_id INTEGER NOT NULL REFERENCES lists(id),
+  post_id TEXT NOT NULL REFERENCES posts(id),
+  position INTEGER NOT NULL
+);
+
+CREATE TABLE follows (
+  kind TEXT NOT NULL,
+  name TEXT,
+  username TEXT,
+  url TEXT
+);
+
+CREATE TABLE interests (
+  kind TEXT NOT NULL,
+  name TEXT,
+  url TEXT
+);
+
+CREATE TABLE blocks (
+  name TEXT,
+  username TEXT,
+  url TEXT
+);
+
+CREATE TABLE sessions (
+  created_at TEXT,
+  last_seen_at TEXT,
+  last_seen_location TEXT,
+  user_agent TEXT
+);
+
+CREATE TABLE ips (
+  address TEXT,
+  created_at TEXT
+);
+
+CREATE TABLE profile (
+  user_id TEXT,
+  username TEXT,
+  name TEXT,
+  email TEXT,
+  bio TEXT,
+  url TEXT,
+  created_at TEXT
+);
+
+CREATE TABLE memberships (
+  id TEXT,
+  started_at TEXT,
+  ended_at TEXT,
+  amount REAL,
+  type TEXT
+);
+
+CREATE TABLE charges (
+  created_at TEXT,
+  amount REAL
+);
+
+`
+
+// SQLiteWriter writes the entire archive into meh.sql, a SQL dump with
+// normalized tables for posts and every other dataset. Everything that
+// references a post does it through the Medium post ID. Load it with:
+//
+//	sqlite3 meh.db < meh.sql
+//
+// The dump doesn't need cgo or any drivers to be produced.
+//
+// SQLiteWriter creates meh.sql on Begin, commits the transaction on End and
+// flushes the file on Close.
+type SQLiteWriter struct {
+	logger *log.Logger
+	root   string
+	file   *os.File
+	out    *bufio.Writer
+	ids    map[string]int
+}
+
+func NewSQLiteWriter(root string, logger *log.Logger) *SQLiteWriter {
+	return &SQLiteWriter{
+		logger: logger,
+		root:   root,
+		ids:    map[string]int{},
+	}
+}
+
+// Begin creates meh.sql and writes the schema
+func (w *SQLiteWriter) Begin() error {
+	err := os.MkdirAll(w.root, os.ModePerm)
+	if err != nil {
+		w.logger.Printf("can't create meh.sql: %v", err)
+		return err
+	}
+
+	w.file, err = os.Create(filepath.Join(w.root, "meh.sql"))
+	if err != nil {
+		w.logger.Printf("can't create meh.sql: %v", err)
+		return err
+	}
+	RecordOutput(w.root, "meh.sql")
+
+	w.out = bufio.NewWriter(w.file)
+	w.out.WriteString(sqliteSchema)
+	return nil
+}
+
+func (w *SQLiteWriter) WritePost(name string, post schema.Post) error {
+	w.writePost(post, "posts/"+postDir(post)+"/"+name)
+	return nil
+}
+
+func (w *SQLiteWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	for _, u := range v.Users {
+		w.insert("blocks", u.Name, u.Username, u.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteBookmarks(v schema.Bookmarks) error {
+	for i, p := range v.Posts {
+		w.insert("bookmarks", w.postRef(p), i)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteClaps(v schema.Claps) error {
+	for _, c := range v.Claps {
+		w.insert("claps", w.postRef(c.Post), c.Amount)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteHighlights(v schema.Highlights) error {
+	for _, h := range v.Highlights {
+		id := w.next("highlights")
+		text := []string{}
+		for _, g := range h.Body {
+			text = append(text, g.Text)
+		}
+		w.insert("highlights", id, h.CreatedAt, strings.Join(text, "\n"))
+
+		for i, g := range h.Body {
+			w.writeGraf(g, i, nil, id, nil)
+		}
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteInterests(v schema.Interests) error {
+	for _, p := range v.Publications {
+		w.insert("interests", "publication", p.Name, p.Url)
+	}
+	for _, t := range v.Tags {
+		w.insert("interests", "tag", t.Name, t.Url)
+	}
+	for _, t := range v.Topics {
+		w.insert("interests", "topic", t.Name, t.Url)
+	}
+	for _, u := range v.Writers {
+		w.insert("interests", "writer", u.Name, u.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteIPs(v schema.IPs) error {
+	for _, ip := range v.IPs {
+		w.insert("ips", ip.Address, ip.CreatedAt)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteLists(v schema.Lists) error {
+	for _, l := range v.Lists {
+		id := w.next("lists")
+		w.insert("lists", id, l.Name, l.Summary)
+		for i, p := range l.Posts {
+			w.insert("list_posts", id, w.postRef(p), i)
+		}
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteFollowedPublications(v schema.Publications) error {
+	for _, p := range v.Publications {
+		w.insert("follows", "publication", p.Name, "", p.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteFollowedTopics(v schema.Topics) error {
+	for _, t := range v.Topics {
+		w.insert("follows", "topic", t.Name, "", t.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteFollowedUsers(v schema.Users) error {
+	for _, u := range v.Users {
+		w.insert("follows", "user", u.Name, u.Username, u.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteSuggestedUsers(v schema.Users) error {
+	for _, u := range v.Users {
+		w.insert("follows", "suggested", u.Name, u.Username, u.Url)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteSessions(v schema.Sessions) error {
+	for _, s := range v.Sessions {
+		w.insert("sessions", s.CreatedAt, s.LastSeenAt, s.LastSeenLocation, s.UserAgent)
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) WriteProfile(v schema.Profile) error {
+	if v.User != nil {
+		u := v.User
+		w.insert("profile", u.Id, u.Username, u.Name, v.Email, u.Bio, u.Url, u.CreatedAt)
+	}
+	for _, m := range v.Memberships {
+		w.insert("memberships", m.Id, m.StartedAt, m.EndedAt, m.Amount, m.Type)
+	}
+	for _, c := range v.MembershipCharges {
+		w.insert("charges", c.CreatedAt, c.Amount)
+	}
+	return nil
+}
+
+// End commits the transaction
+func (w *SQLiteWriter) End() error {
+	if w.out == nil {
+		return nil
+	}
+
+	w.out.WriteString("\nCOMMIT;\n")
+	return nil
+}
+
+// Close flushes and closes meh.sql
+func (w *SQLiteWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.out.Flush()
+	if err != nil {
+		w.logger.Printf("can't write meh.sql: %v", err)
+		w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+func (w *SQLiteWriter) writePost(p schema.Post, fp string) {
+	id := p.Id
+	if id == "" {
+		id = fp
+	}
+
+	author := ""
+	if p.Author != nil {
+		author = p.Author.Username
+	}
+
+	fmt.Fprintf(w.out, "INSERT INTO posts (id, url, title, subtitle, author_username, status, kind, published_at, file) VALUES (%s) "+
+		"ON CONFLICT(id) DO UPDATE SET url = excluded.url, title = excluded.title, subtitle = excluded.subtitle, "+
+		"author_username = excluded.author_username, status = excluded.status, kind = excluded.kind, "+
+		"published_at = excluded.published_at, file = excluded.file;\n",
+		sqlValues(id, p.Url, p.Title, p.Subtitle, author, string(p.Status), string(p.Kind), p.PublishedAt, fp))
+
+	for i, s := range p.Content {
+		sid := w.next("sections")
+		w.insert("sections", sid, id, i, s.Name)
+
+		for j, inner := range s.Body {
+			iid := w.next("inner_sections")
+			w.insert("inner_sections", iid, sid, j, strings.Join(inner.Classes, " "))
+
+			for k, g := range inner.Body {
+				w.writeGraf(g, k, iid, nil, nil)
+			}
+		}
+	}
+}
+
+// writeGraf stores a graf that belongs either to a post (through an inner
+// section) or to a highlight. Every image of a figure goes into images
+// while image_* columns keep the first one. List items are stored as grafs
+// of their own that point to the list through parent_id.
+func (w *SQLiteWriter) writeGraf(g schema.Graf, position int, inner, highlight, parent any) {
+	id := w.next("grafs")
+
+	img := schema.Image{}
+	if g.Image != nil {
+		img = *g.Image
+	}
+
+	embed := schema.Embed{}
+	if g.Embed != nil {
+		embed = *g.Embed
+	}
+
+	w.insert("grafs", id, inner, highlight, position, string(g.Type), g.Name, g.Text,
+		img.Name, img.Source, img.Width, img.Height, parent,
+		string(embed.Provider), embed.Source, embed.Title, embed.Description, string(g.Layout))
+
+	for i, img := range g.Images {
+		w.insert("images", id, i, img.Name, img.Source, img.Alt, img.Width, img.Height)
+	}
+
+	for i, m := range g.Markups {
+		w.insert("markups", w.next("markups"), id, i, string(m.Type), m.Start, m.End, m.Href)
+	}
+
+	for i, item := range g.Items {
+		w.writeGraf(item, i, inner, highlight, id)
+	}
+}
+
+// postRef makes sure a post referenced by other datasets (claps, bookmarks,
+// lists) exists in the posts table and returns its ID.
+func (w *SQLiteWriter) postRef(p schema.Post) string {
+	id := p.Id
+	if id == "" {
+		id = p.Url
+	}
+
+	fmt.Fprintf(w.out, "INSERT OR IGNORE INTO posts (id, url, title, published_at) VALUES (%s);\n",
+		sqlValues(id, p.Url, p.Title, p.PublishedAt))
+	return id
+}
+
+func (w *SQLiteWriter) insert(table string, values ...any) {
+	fmt.Fprintf(w.out, "INSERT INTO %s VALUES (%s);\n", table, sqlValues(values...))
+}
+
+// next returns the next integer primary key for a table
+func (w *SQLiteWriter) next(table string) int {
+	w.ids[table]++
+	return w.ids[table]
+}
+
+func sqlValues(values ...any) string {
+	out := []string{}
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			out = append(out, "NULL")
+		case int:
+			out = append(out, strconv.Itoa(v))
+		case float64:
+			out = append(out, strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			out = append(out, "'"+strings.ReplaceAll(v, "'", "''")+"'")
+		default:
+			out = append(out, "'"+strings.ReplaceAll(fmt.Sprint(v), "'", "''")+"'")
+		}
+	}
+	return strings.Join(out, ", ")
+}
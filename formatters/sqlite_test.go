@@ -0,0 +1,71 @@
+package formatters_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestSQLiteWriter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewSQLiteWriter(root, log.New(io.Discard, "", 0))
+
+	err := w.Begin()
+	if err != nil {
+		t.Fatalf("can't begin: %v", err)
+	}
+
+	w.WriteClaps(schema.Claps{Claps: []schema.Clap{
+		{Amount: 50, Post: schema.Post{Id: "3d26424537aa", Title: "I Accidentally Bought a Banksy in 2003"}},
+	}})
+	w.WritePost("basic", schema.Post{
+		Id:       "70c5683f3778",
+		Title:    "oh, right",
+		Subtitle: "a poem",
+		Author:   &schema.User{Username: "anton"},
+		Status:   schema.PUBLISHED,
+		Kind:     schema.STORY,
+		Content: []schema.Section{
+			{Name: "1901", Body: []schema.InnerSection{{Body: []schema.Graf{
+				{Type: schema.P, Text: "it's early", Markups: []schema.Markup{{Type: schema.EM, Start: 0, End: 4}}},
+			}}}},
+		},
+	})
+	w.WriteProfile(schema.Profile{
+		User:              &schema.User{Username: "anton"},
+		MembershipCharges: []schema.MembershipCharge{{CreatedAt: "2022-01-01", Amount: 5.5}},
+	})
+
+	w.End()
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("can't close writer: %v", err)
+	}
+
+	dat, _ := os.ReadFile(filepath.Join(root, "meh.sql"))
+	have := string(dat)
+
+	for _, want := range []string{
+		"INSERT OR IGNORE INTO posts (id, url, title, published_at) VALUES ('3d26424537aa', '', 'I Accidentally Bought a Banksy in 2003', '');",
+		"INSERT INTO claps VALUES ('3d26424537aa', 50);",
+		"VALUES ('70c5683f3778', '', 'oh, right', 'a poem', 'anton', 'published', 'story', '', 'posts/stories/basic')",
+		"INSERT INTO sections VALUES (1, '70c5683f3778', 0, '1901');",
+		"INSERT INTO grafs VALUES (1, 1, NULL, 0, 'p', '', 'it''s early', '', '', '', '', NULL, '', '', '', '', '');",
+		"INSERT INTO markups VALUES (1, 1, 0, 'em', 0, 4, '');",
+		"INSERT INTO charges VALUES ('2022-01-01', 5.5);",
+	} {
+		if !strings.Contains(have, want) {
+			t.Errorf("meh.sql doesn't contain %s", want)
+		}
+	}
+
+	if !strings.HasSuffix(have, "COMMIT;\n") {
+		t.Errorf("meh.sql doesn't commit the transaction")
+	}
+}
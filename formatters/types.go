@@ -1,7 +1,23 @@
 package formatters
 
+import "io"
+
 // Formatter is an interface implemented by types that can
 // transform Medium export data into a different format.
+//
+// Formatters that need to see all data before they can finish
+// writing (an index page, for example) should also implement
+// io.Closer. See Writer for a richer alternative with typed
+// methods and a lifecycle.
 type Formatter interface {
 	WriteFile(fp string, v any) error
 }
+
+// Close finalizes a formatter if it implements io.Closer. It
+// should be called once after all data was written.
+func Close(f Formatter) error {
+	if c, ok := f.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
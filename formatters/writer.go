@@ -0,0 +1,294 @@
+package formatters
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/valueof/meh/schema"
+)
+
+// Writer is a richer alternative to Formatter with a method for every
+// kind of export data and a lifecycle around them:
+//
+//   - Begin is called once before any data is written
+//   - Write* methods are called once per dataset, WritePost once per post
+//   - End is called once after all data was written
+//   - Close is called last, after images were downloaded, to finish and
+//     flush any output
+//
+// Writers that keep global state (an index page, a single database file)
+// can set it up in Begin and finish it in End or Close. Embed NopWriter to
+// only implement the methods you need.
+//
+// Writers don't have to be thread-safe: the parser wraps them with
+// NewSyncWriter and writes data in the same order on every run.
+type Writer interface {
+	Begin() error
+
+	// WritePost receives a post with name of the file it came from,
+	// without an extension
+	WritePost(name string, post schema.Post) error
+	WriteBlockedUsers(v schema.BlockedUsers) error
+	WriteBookmarks(v schema.Bookmarks) error
+	WriteClaps(v schema.Claps) error
+	WriteHighlights(v schema.Highlights) error
+	WriteInterests(v schema.Interests) error
+	WriteIPs(v schema.IPs) error
+	WriteLists(v schema.Lists) error
+	WriteFollowedPublications(v schema.Publications) error
+	WriteFollowedTopics(v schema.Topics) error
+	WriteFollowedUsers(v schema.Users) error
+	WriteSuggestedUsers(v schema.Users) error
+	WriteSessions(v schema.Sessions) error
+	WriteProfile(v schema.Profile) error
+
+	End() error
+	Close() error
+}
+
+// NewWriter turns a Formatter into a Writer. Every dataset is passed to
+// WriteFile with the same path it always had (claps, following/users, etc.)
+// and Close closes the formatter if it implements io.Closer. Writers are
+// returned as is.
+//
+// Posts go into posts/stories/<name>, posts/responses/<name> or
+// posts/drafts/<name>, see postDir. End writes a schema.PostIndex into
+// posts/<dir>/index for every one of them that isn't empty.
+func NewWriter(f Formatter) Writer {
+	if w, ok := f.(Writer); ok {
+		return w
+	}
+	return &formatterWriter{f: f, indexes: map[string][]schema.PostIndexEntry{}}
+}
+
+// postDirs lists directories for posts with their index descriptions
+var postDirs = []struct {
+	Name string
+	Meta string
+}{
+	{"stories", "Your published stories"},
+	{"responses", "Your responses to other posts"},
+	{"drafts", "Your drafts"},
+}
+
+// postDir returns the directory under posts/ that a post belongs to:
+// drafts for unpublished posts (including unpublished responses),
+// responses for published responses and stories for everything else.
+func postDir(post schema.Post) string {
+	switch {
+	case post.Status == schema.DRAFT:
+		return "drafts"
+	case post.Kind == schema.RESPONSE:
+		return "responses"
+	default:
+		return "stories"
+	}
+}
+
+type formatterWriter struct {
+	f       Formatter
+	indexes map[string][]schema.PostIndexEntry
+}
+
+func (w *formatterWriter) Begin() error {
+	return nil
+}
+
+func (w *formatterWriter) WritePost(name string, post schema.Post) error {
+	dir := postDir(post)
+	w.indexes[dir] = append(w.indexes[dir], schema.PostIndexEntry{
+		Name:        name,
+		Title:       post.Title,
+		PublishedAt: post.PublishedAt,
+		Url:         post.Url,
+		InReplyTo:   post.InReplyTo,
+	})
+
+	return w.f.WriteFile(filepath.Join("posts", dir, name), post)
+}
+
+func (w *formatterWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	return w.f.WriteFile("blocks", v)
+}
+
+func (w *formatterWriter) WriteBookmarks(v schema.Bookmarks) error {
+	return w.f.WriteFile("bookmarks", v)
+}
+
+func (w *formatterWriter) WriteClaps(v schema.Claps) error {
+	return w.f.WriteFile("claps", v)
+}
+
+func (w *formatterWriter) WriteHighlights(v schema.Highlights) error {
+	return w.f.WriteFile("highlights", v)
+}
+
+func (w *formatterWriter) WriteInterests(v schema.Interests) error {
+	return w.f.WriteFile("interests", v)
+}
+
+func (w *formatterWriter) WriteIPs(v schema.IPs) error {
+	return w.f.WriteFile("ips", v)
+}
+
+func (w *formatterWriter) WriteLists(v schema.Lists) error {
+	return w.f.WriteFile("lists", v)
+}
+
+func (w *formatterWriter) WriteFollowedPublications(v schema.Publications) error {
+	return w.f.WriteFile(filepath.Join("following", "publications"), v)
+}
+
+func (w *formatterWriter) WriteFollowedTopics(v schema.Topics) error {
+	return w.f.WriteFile(filepath.Join("following", "topics"), v)
+}
+
+func (w *formatterWriter) WriteFollowedUsers(v schema.Users) error {
+	return w.f.WriteFile(filepath.Join("following", "users"), v)
+}
+
+func (w *formatterWriter) WriteSuggestedUsers(v schema.Users) error {
+	return w.f.WriteFile(filepath.Join("following", "suggested"), v)
+}
+
+func (w *formatterWriter) WriteSessions(v schema.Sessions) error {
+	return w.f.WriteFile("sessions", v)
+}
+
+func (w *formatterWriter) WriteProfile(v schema.Profile) error {
+	return w.f.WriteFile("profile", v)
+}
+
+func (w *formatterWriter) End() error {
+	for _, dir := range postDirs {
+		entries := w.indexes[dir.Name]
+		if len(entries) == 0 {
+			continue
+		}
+
+		err := w.f.WriteFile(filepath.Join("posts", dir.Name, "index"), schema.PostIndex{
+			Meta:  dir.Meta,
+			Posts: entries,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *formatterWriter) Close() error {
+	return Close(w.f)
+}
+
+// NopWriter implements every Writer method as a no-op. Embed it into
+// writers that only care about some of the data.
+type NopWriter struct{}
+
+func (NopWriter) Begin() error                                          { return nil }
+func (NopWriter) WritePost(name string, post schema.Post) error         { return nil }
+func (NopWriter) WriteBlockedUsers(v schema.BlockedUsers) error         { return nil }
+func (NopWriter) WriteBookmarks(v schema.Bookmarks) error               { return nil }
+func (NopWriter) WriteClaps(v schema.Claps) error                       { return nil }
+func (NopWriter) WriteHighlights(v schema.Highlights) error             { return nil }
+func (NopWriter) WriteInterests(v schema.Interests) error               { return nil }
+func (NopWriter) WriteIPs(v schema.IPs) error                           { return nil }
+func (NopWriter) WriteLists(v schema.Lists) error                       { return nil }
+func (NopWriter) WriteFollowedPublications(v schema.Publications) error { return nil }
+func (NopWriter) WriteFollowedTopics(v schema.Topics) error             { return nil }
+func (NopWriter) WriteFollowedUsers(v schema.Users) error               { return nil }
+func (NopWriter) WriteSuggestedUsers(v schema.Users) error              { return nil }
+func (NopWriter) WriteSessions(v schema.Sessions) error                 { return nil }
+func (NopWriter) WriteProfile(v schema.Profile) error                   { return nil }
+func (NopWriter) End() error                                            { return nil }
+func (NopWriter) Close() error                                          { return nil }
+
+// NewSyncWriter wraps w so that its methods can be called from several
+// goroutines. Calls are serialized with a mutex, w itself doesn't need to
+// be thread-safe.
+func NewSyncWriter(w Writer) Writer {
+	if _, ok := w.(*syncWriter); ok {
+		return w
+	}
+	return &syncWriter{w: w}
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  Writer
+}
+
+func (s *syncWriter) do(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
+}
+
+func (s *syncWriter) Begin() error {
+	return s.do(s.w.Begin)
+}
+
+func (s *syncWriter) WritePost(name string, post schema.Post) error {
+	return s.do(func() error { return s.w.WritePost(name, post) })
+}
+
+func (s *syncWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	return s.do(func() error { return s.w.WriteBlockedUsers(v) })
+}
+
+func (s *syncWriter) WriteBookmarks(v schema.Bookmarks) error {
+	return s.do(func() error { return s.w.WriteBookmarks(v) })
+}
+
+func (s *syncWriter) WriteClaps(v schema.Claps) error {
+	return s.do(func() error { return s.w.WriteClaps(v) })
+}
+
+func (s *syncWriter) WriteHighlights(v schema.Highlights) error {
+	return s.do(func() error { return s.w.WriteHighlights(v) })
+}
+
+func (s *syncWriter) WriteInterests(v schema.Interests) error {
+	return s.do(func() error { return s.w.WriteInterests(v) })
+}
+
+func (s *syncWriter) WriteIPs(v schema.IPs) error {
+	return s.do(func() error { return s.w.WriteIPs(v) })
+}
+
+func (s *syncWriter) WriteLists(v schema.Lists) error {
+	return s.do(func() error { return s.w.WriteLists(v) })
+}
+
+func (s *syncWriter) WriteFollowedPublications(v schema.Publications) error {
+	return s.do(func() error { return s.w.WriteFollowedPublications(v) })
+}
+
+func (s *syncWriter) WriteFollowedTopics(v schema.Topics) error {
+	return s.do(func() error { return s.w.WriteFollowedTopics(v) })
+}
+
+func (s *syncWriter) WriteFollowedUsers(v schema.Users) error {
+	return s.do(func() error { return s.w.WriteFollowedUsers(v) })
+}
+
+func (s *syncWriter) WriteSuggestedUsers(v schema.Users) error {
+	return s.do(func() error { return s.w.WriteSuggestedUsers(v) })
+}
+
+func (s *syncWriter) WriteSessions(v schema.Sessions) error {
+	return s.do(func() error { return s.w.WriteSessions(v) })
+}
+
+func (s *syncWriter) WriteProfile(v schema.Profile) error {
+	return s.do(func() error { return s.w.WriteProfile(v) })
+}
+
+func (s *syncWriter) End() error {
+	return s.do(s.w.End)
+}
+
+func (s *syncWriter) Close() error {
+	return s.do(s.w.Close)
+}
@@ -0,0 +1,72 @@
+package formatters_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+type recorder struct {
+	paths  []string
+	closed bool
+}
+
+func (r *recorder) WriteFile(fp string, v any) error {
+	r.paths = append(r.paths, fp)
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+// native is a Writer that also implements Formatter
+type native struct {
+	formatters.NopWriter
+}
+
+func (n *native) WriteFile(fp string, v any) error {
+	return nil
+}
+
+func TestNewWriter(t *testing.T) {
+	r := &recorder{}
+	w := formatters.NewWriter(r)
+
+	w.Begin()
+	w.WritePost("basic", schema.Post{Kind: schema.STORY})
+	w.WritePost("reply", schema.Post{Kind: schema.RESPONSE, InReplyTo: &schema.Post{Id: "70c5683f3778"}})
+	w.WritePost("draft_reply", schema.Post{Status: schema.DRAFT, Kind: schema.RESPONSE})
+	w.WriteClaps(schema.Claps{})
+	w.WriteFollowedUsers(schema.Users{})
+	w.WriteSuggestedUsers(schema.Users{})
+	w.WriteProfile(schema.Profile{})
+	w.End()
+
+	if r.closed {
+		t.Errorf("formatter was closed before Close")
+	}
+
+	w.Close()
+
+	want := []string{
+		"posts/stories/basic", "posts/responses/reply", "posts/drafts/draft_reply",
+		"claps", "following/users", "following/suggested", "profile",
+		"posts/stories/index", "posts/responses/index", "posts/drafts/index",
+	}
+	if !reflect.DeepEqual(r.paths, want) {
+		t.Errorf("want: %v; have: %v", want, r.paths)
+	}
+
+	if !r.closed {
+		t.Errorf("formatter wasn't closed")
+	}
+
+	n := &native{}
+	if formatters.NewWriter(n) != formatters.Writer(n) {
+		t.Errorf("writers should be returned as is")
+	}
+}
@@ -0,0 +1,512 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+var wxrTemplate = template.Must(template.New("wxr").Funcs(template.FuncMap{
+	"esc":   html.EscapeString,
+	"cdata": cdata,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+  xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+  xmlns:content="http://purl.org/rss/1.0/modules/content/"
+  xmlns:wfw="http://wellformedweb.org/CommentAPI/"
+  xmlns:dc="http://purl.org/dc/elements/1.1/"
+  xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+  <title>{{esc .Title}}</title>
+  <link>https://medium.com</link>
+  <description>{{esc .Title}}</description>
+  <language>en</language>
+  <wp:wxr_version>1.2</wp:wxr_version>
+  <wp:base_site_url>https://medium.com</wp:base_site_url>
+  <wp:base_blog_url>https://medium.com</wp:base_blog_url>
+  {{if .Author}}<wp:author>
+    <wp:author_login>{{cdata .Author.Login}}</wp:author_login>
+    <wp:author_email>{{cdata .Author.Email}}</wp:author_email>
+    <wp:author_display_name>{{cdata .Author.Name}}</wp:author_display_name>
+  </wp:author>
+  {{end}}{{range .Terms}}<wp:term>
+    <wp:term_id>{{.Id}}</wp:term_id>
+    <wp:term_taxonomy>{{.Taxonomy}}</wp:term_taxonomy>
+    <wp:term_slug>{{cdata .Slug}}</wp:term_slug>
+    <wp:term_name>{{cdata .Name}}</wp:term_name>
+    {{if .Description}}<wp:term_description>{{cdata .Description}}</wp:term_description>
+    {{end}}</wp:term>
+  {{end}}{{range .Items}}<item>
+    <title>{{esc .Title}}</title>
+    <link>{{esc .Link}}</link>
+    {{if .PubDate}}<pubDate>{{.PubDate}}</pubDate>
+    {{end}}<dc:creator>{{cdata .Creator}}</dc:creator>
+    <guid isPermaLink="false">{{esc .Guid}}</guid>
+    <description></description>
+    <content:encoded>{{cdata .Content}}</content:encoded>
+    <excerpt:encoded>{{cdata .Excerpt}}</excerpt:encoded>
+    <wp:post_id>{{.Id}}</wp:post_id>
+    {{if .Date}}<wp:post_date>{{cdata .Date}}</wp:post_date>
+    <wp:post_date_gmt>{{cdata .Date}}</wp:post_date_gmt>
+    {{end}}<wp:comment_status>closed</wp:comment_status>
+    <wp:ping_status>closed</wp:ping_status>
+    <wp:post_name>{{cdata .Slug}}</wp:post_name>
+    <wp:status>{{.Status}}</wp:status>
+    <wp:post_parent>{{.Parent}}</wp:post_parent>
+    <wp:menu_order>0</wp:menu_order>
+    <wp:post_type>{{.Type}}</wp:post_type>
+    <wp:post_password></wp:post_password>
+    <wp:is_sticky>0</wp:is_sticky>
+    {{if .AttachmentUrl}}<wp:attachment_url>{{esc .AttachmentUrl}}</wp:attachment_url>
+    {{end}}{{range .Terms}}<category domain="{{.Taxonomy}}" nicename="{{esc .Slug}}">{{cdata .Name}}</category>
+    {{end}}{{range .Meta}}<wp:postmeta>
+      <wp:meta_key>{{cdata .Key}}</wp:meta_key>
+      <wp:meta_value>{{cdata .Value}}</wp:meta_value>
+    </wp:postmeta>
+    {{end}}</item>
+  {{end}}
+</channel>
+</rss>
+`))
+
+// WXRTaxonomy is the custom taxonomy that WXRFormatter uses for Medium
+// bookmarks and lists. Register it in WordPress to see the terms.
+const WXRTaxonomy = "medium_list"
+
+type wxrAuthor struct {
+	Login string
+	Email string
+	Name  string
+}
+
+type wxrTerm struct {
+	Id          int
+	Taxonomy    string
+	Slug        string
+	Name        string
+	Description string
+}
+
+type wxrMeta struct {
+	Key   string
+	Value string
+}
+
+type wxrItem struct {
+	Id            int
+	Title         string
+	Link          string
+	PubDate       string
+	Date          string
+	Creator       string
+	Guid          string
+	Content       string
+	Excerpt       string
+	Slug          string
+	Status        string
+	Parent        int
+	Type          string
+	AttachmentUrl string
+	Terms         []*wxrTerm
+	Meta          []wxrMeta
+}
+
+type wxrChannel struct {
+	Title  string
+	Author *wxrAuthor
+	Terms  []*wxrTerm
+	Items  []wxrItem
+}
+
+// WXRFormatter writes all posts into a single WordPress eXtended RSS file
+// that can be imported with the WordPress importer. Post content is made
+// of Gutenberg blocks and images become attachments. Bookmarks and lists
+// become terms of a custom taxonomy (WXRTaxonomy) on posts they contain.
+// All other export data is passed along to JSONFormatter.
+//
+// WXRFormatter writes wordpress.xml on Close.
+type WXRFormatter struct {
+	jsonFallback
+	logger    *log.Logger
+	root      string
+	posts     []schema.Post
+	names     []string
+	profile   *schema.Profile
+	bookmarks []schema.Post
+	lists     []schema.List
+}
+
+func NewWXRFormatter(root string, logger *log.Logger) *WXRFormatter {
+	return &WXRFormatter{
+		logger:       logger,
+		root:         root,
+		jsonFallback: newJSONFallback(root, logger),
+		posts:        []schema.Post{},
+		names:        []string{},
+	}
+}
+
+func (w *WXRFormatter) WriteFile(fp string, v any) error {
+	switch v := v.(type) {
+	case schema.Post:
+		w.posts = append(w.posts, v)
+		w.names = append(w.names, filepath.Base(fp))
+		return nil
+	case schema.Profile:
+		w.profile = &v
+	case schema.Bookmarks:
+		w.bookmarks = v.Posts
+	case schema.Lists:
+		w.lists = v.Lists
+	}
+
+	return w.fallback(fp, v)
+}
+
+// Close writes wordpress.xml
+func (w *WXRFormatter) Close() error {
+	channel := wxrChannel{
+		Title: "Medium stories",
+		Terms: []*wxrTerm{},
+		Items: []wxrItem{},
+	}
+
+	creator := "medium"
+	if w.profile != nil && w.profile.User != nil {
+		user := w.profile.User
+		if user.Username != "" {
+			creator = user.Username
+		}
+
+		name := user.Name
+		if name == "" {
+			name = creator
+		}
+
+		channel.Title = "Stories by " + name
+		channel.Author = &wxrAuthor{
+			Login: creator,
+			Email: w.profile.Email,
+			Name:  name,
+		}
+	}
+
+	// Terms for bookmarks and lists, keyed by post ID
+	terms := map[string][]*wxrTerm{}
+	addTerm := func(name, description string, posts []schema.Post) {
+		term := &wxrTerm{
+			Id:          len(channel.Terms) + 1,
+			Taxonomy:    WXRTaxonomy,
+			Slug:        slugify(name),
+			Name:        name,
+			Description: description,
+		}
+		if term.Slug == "" {
+			term.Slug = fmt.Sprintf("list-%d", term.Id)
+		}
+		channel.Terms = append(channel.Terms, term)
+
+		for _, p := range posts {
+			if p.Id != "" {
+				terms[p.Id] = append(terms[p.Id], term)
+			}
+		}
+	}
+
+	if len(w.bookmarks) > 0 {
+		addTerm("Bookmarks", "Posts bookmarked on Medium", w.bookmarks)
+	}
+
+	for _, l := range w.lists {
+		addTerm(l.Name, l.Summary, l.Posts)
+	}
+
+	// Responses go into a regular category so they can be hidden or styled
+	// differently, it's created along with the first response
+	var responses *wxrTerm
+
+	attachments := []wxrItem{}
+	for i, post := range w.posts {
+		id := i + 1
+
+		item := wxrItem{
+			Id:      id,
+			Title:   post.Title,
+			Link:    post.Url,
+			Creator: creator,
+			Guid:    post.Id,
+			Content: gutenbergPost(stripTitle(post)),
+			Slug:    util.ParseMediumSlug(post.Url),
+			Status:  "draft",
+			Type:    "post",
+			Terms:   terms[post.Id],
+			Meta:    []wxrMeta{},
+		}
+
+		if item.Guid == "" {
+			item.Guid = w.names[i]
+		}
+
+		if post.Url != "" {
+			item.Meta = append(item.Meta, wxrMeta{"medium_url", post.Url})
+		}
+
+		if post.Kind == schema.RESPONSE {
+			if responses == nil {
+				responses = &wxrTerm{
+					Id:          len(channel.Terms) + 1,
+					Taxonomy:    "category",
+					Slug:        "responses",
+					Name:        "Responses",
+					Description: "Responses to other posts on Medium",
+				}
+				channel.Terms = append(channel.Terms, responses)
+			}
+			item.Terms = append(append([]*wxrTerm{}, item.Terms...), responses)
+
+			if post.InReplyTo != nil && post.InReplyTo.Url != "" {
+				item.Meta = append(item.Meta, wxrMeta{"medium_in_reply_to", post.InReplyTo.Url})
+			}
+		}
+
+		if t, err := time.Parse(time.RFC3339, post.PublishedAt); err == nil {
+			item.Status = "publish"
+			item.PubDate = t.UTC().Format(time.RFC1123Z)
+			item.Date = t.UTC().Format("2006-01-02 15:04:05")
+		}
+
+		channel.Items = append(channel.Items, item)
+
+		forEachGraf(post, func(g schema.Graf) {
+			for _, img := range grafImages(g) {
+				src := imageSource(img)
+				attachments = append(attachments, wxrItem{
+					Title:         strings.TrimSuffix(path.Base(src), path.Ext(src)),
+					Link:          src,
+					PubDate:       item.PubDate,
+					Date:          item.Date,
+					Creator:       creator,
+					Guid:          src,
+					Excerpt:       img.Alt,
+					Slug:          slugify(img.Name),
+					Status:        "inherit",
+					Parent:        id,
+					Type:          "attachment",
+					AttachmentUrl: src,
+					Meta:          []wxrMeta{},
+				})
+			}
+		})
+	}
+
+	for i := range attachments {
+		attachments[i].Id = len(w.posts) + i + 1
+		channel.Items = append(channel.Items, attachments[i])
+	}
+
+	var b bytes.Buffer
+	err := wxrTemplate.Execute(&b, channel)
+	if err != nil {
+		w.logger.Printf("can't render wordpress.xml: %v", err)
+		return err
+	}
+
+	err = writeOutput(w.root, "wordpress.xml", b.Bytes())
+	if err != nil {
+		w.logger.Printf("can't write wordpress.xml: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// gutenbergPost renders post content as WordPress block editor markup.
+func gutenbergPost(post schema.Post) string {
+	blocks := []string{}
+
+	for i, s := range post.Content {
+		if i > 0 {
+			blocks = append(blocks, gutenbergBlock("separator", "", `<hr class="wp-block-separator"/>`))
+		}
+
+		for _, inner := range s.Body {
+			for _, g := range inner.Body {
+				if b := gutenbergGraf(g); b != "" {
+					blocks = append(blocks, b)
+				}
+			}
+		}
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+func gutenbergGraf(g schema.Graf) string {
+	switch g.Type {
+	case schema.H1, schema.H2, schema.H3, schema.H4:
+		level := strings.TrimPrefix(string(g.Type), "h")
+		attrs := ""
+		if level != "2" {
+			attrs = fmt.Sprintf(`{"level":%s}`, level)
+		}
+		return gutenbergBlock("heading", attrs, fmt.Sprintf(`<h%s class="wp-block-heading">%s</h%s>`, level, htmlInline(g.Text, g.Markups), level))
+	case schema.EMBED:
+		if g.Embed != nil {
+			return gutenbergEmbed(g)
+		}
+		return gutenbergBlock("paragraph", "", "<p>"+htmlInline(g.Text, g.Markups)+"</p>")
+	case schema.P:
+		return gutenbergBlock("paragraph", "", "<p>"+htmlInline(g.Text, g.Markups)+"</p>")
+	case schema.BLOCKQUOTE:
+		return gutenbergBlock("quote", "", `<blockquote class="wp-block-quote"><p>`+htmlInline(g.Text, g.Markups)+"</p></blockquote>")
+	case schema.PRE:
+		return gutenbergBlock("code", "", `<pre class="wp-block-code"><code>`+html.EscapeString(strings.TrimRight(g.Text, "\n"))+"</code></pre>")
+	case schema.IMG:
+		return gutenbergImages(g)
+	case schema.OL, schema.UL:
+		items := []string{}
+		for _, item := range g.Items {
+			items = append(items, "<li>"+htmlInline(item.Text, item.Markups)+"</li>")
+		}
+		attrs := ""
+		if g.Type == schema.OL {
+			attrs = `{"ordered":true}`
+		}
+		return gutenbergBlock("list", attrs, fmt.Sprintf("<%s>%s</%s>", g.Type, strings.Join(items, ""), g.Type))
+	case schema.HR:
+		return gutenbergBlock("separator", "", `<hr class="wp-block-separator"/>`)
+	}
+
+	return ""
+}
+
+// gutenbergImages turns a figure into an image block, or a gallery of image
+// blocks when it has several images.
+func gutenbergImages(g schema.Graf) string {
+	images := grafImages(g)
+	if len(images) == 0 {
+		return ""
+	}
+
+	caption := ""
+	if g.Text != "" {
+		caption = htmlInline(g.Text, g.Markups)
+	}
+
+	if len(images) == 1 {
+		img := images[0]
+		align := gutenbergAlign(g.Layout)
+
+		attrs, class := "", "wp-block-image"
+		if align != "" {
+			attrs, class = fmt.Sprintf(`{"align":"%s"}`, align), class+" align"+align
+		}
+
+		if caption != "" {
+			caption = `<figcaption class="wp-element-caption">` + caption + "</figcaption>"
+		}
+
+		content := fmt.Sprintf(`<figure class="%s"><img src="%s" alt="%s"/>%s</figure>`, class, html.EscapeString(imageSource(img)), html.EscapeString(img.Alt), caption)
+		return gutenbergBlock("image", attrs, content)
+	}
+
+	blocks := []string{}
+	for _, img := range images {
+		blocks = append(blocks, gutenbergBlock("image", "", fmt.Sprintf(`<figure class="wp-block-image"><img src="%s" alt="%s"/></figure>`, html.EscapeString(imageSource(img)), html.EscapeString(img.Alt))))
+	}
+
+	if caption != "" {
+		caption = `<figcaption class="blocks-gallery-caption wp-element-caption">` + caption + "</figcaption>"
+	}
+
+	content := `<figure class="wp-block-gallery has-nested-images columns-default is-cropped">` + strings.Join(blocks, "\n") + caption + "</figure>"
+	return gutenbergBlock("gallery", `{"linkTo":"none"}`, content)
+}
+
+// gutenbergAlign maps Medium layouts to WordPress block alignment
+func gutenbergAlign(l schema.Layout) string {
+	switch l {
+	case schema.OUTSET_CENTER:
+		return "wide"
+	case schema.FILL_WIDTH:
+		return "full"
+	case schema.OUTSET_LEFT, schema.INSET_LEFT:
+		return "left"
+	}
+	return ""
+}
+
+// gutenbergEmbed turns videos and tweets into embed blocks so WordPress
+// fetches the player itself. Everything else becomes a link.
+func gutenbergEmbed(g schema.Graf) string {
+	kind := ""
+	switch g.Embed.Provider {
+	case schema.YOUTUBE:
+		kind = "video"
+	case schema.TWITTER:
+		kind = "rich"
+	default:
+		return gutenbergBlock("paragraph", "", "<p>"+htmlEmbed(g)+"</p>")
+	}
+
+	attrs, _ := json.Marshal(map[string]string{
+		"url":              g.Embed.Source,
+		"type":             kind,
+		"providerNameSlug": string(g.Embed.Provider),
+	})
+
+	figure := fmt.Sprintf(`<figure class="wp-block-embed is-type-%s is-provider-%s wp-block-embed-%s"><div class="wp-block-embed__wrapper">`+"\n%s\n"+`</div></figure>`,
+		kind, g.Embed.Provider, g.Embed.Provider, html.EscapeString(g.Embed.Source))
+	return gutenbergBlock("embed", string(attrs), figure)
+}
+
+func gutenbergBlock(name, attrs, content string) string {
+	if attrs != "" {
+		attrs = " " + attrs
+	}
+	return fmt.Sprintf("<!-- wp:%s%s -->\n%s\n<!-- /wp:%s -->", name, attrs, content, name)
+}
+
+// forEachGraf calls fn for every graf in a post.
+func forEachGraf(post schema.Post, fn func(schema.Graf)) {
+	for _, s := range post.Content {
+		for _, inner := range s.Body {
+			for _, g := range inner.Body {
+				fn(g)
+			}
+		}
+	}
+}
+
+// cdata wraps s into a CDATA section, splitting it where s itself
+// contains the CDATA terminator.
+func cdata(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+// slugify turns a name into a lowercase, dash-separated slug.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,97 @@
+package formatters_test
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+func TestWXRFormatter(t *testing.T) {
+	root := t.TempDir()
+	w := formatters.NewWXRFormatter(root, log.New(io.Discard, "", 0))
+
+	w.WriteFile("posts/basic", schema.Post{
+		Id:          "70c5683f3778",
+		Url:         "https://medium.com/@anton/oh-right-70c5683f3778",
+		Title:       "oh, right",
+		PublishedAt: "2015-02-05T02:56:45.739Z",
+		Content: []schema.Section{
+			{Body: []schema.InnerSection{{Body: []schema.Graf{
+				{Type: schema.H3, Text: "oh, right"},
+				{Type: schema.H4, Text: "a poem"},
+				{Type: schema.PRE, Text: "if a ]]> b {}"},
+				{Type: schema.IMG, Image: &schema.Image{Name: "owl.png", Source: "https://cdn-images-1.medium.com/owl.png"}},
+			}}}},
+		},
+	})
+	w.WriteFile("posts/reply", schema.Post{
+		Id:          "e0b4bd5f2c1a",
+		Url:         "https://medium.com/@anton/nice-e0b4bd5f2c1a",
+		Title:       "Nice!",
+		Kind:        schema.RESPONSE,
+		InReplyTo:   &schema.Post{Url: "https://medium.com/@anton/owls-1234567890ab"},
+		PublishedAt: "2016-01-01T10:00:00.000Z",
+	})
+	w.WriteFile("lists", schema.Lists{Lists: []schema.List{
+		{Name: "Poems", Posts: []schema.Post{{Id: "70c5683f3778"}}},
+	}})
+	w.WriteFile("profile", schema.Profile{User: &schema.User{Username: "anton", Name: "Anton Kovalyov"}})
+
+	err := formatters.Close(w)
+	if err != nil {
+		t.Fatalf("can't close formatter: %v", err)
+	}
+
+	dat, err := os.ReadFile(filepath.Join(root, "wordpress.xml"))
+	if err != nil {
+		t.Fatalf("can't read wordpress.xml: %v", err)
+	}
+
+	var rss struct {
+		Items []struct {
+			Title   string `xml:"title"`
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Type    string `xml:"http://wordpress.org/export/1.2/ post_type"`
+			Parent  int    `xml:"http://wordpress.org/export/1.2/ post_parent"`
+		} `xml:"channel>item"`
+	}
+
+	err = xml.Unmarshal(dat, &rss)
+	if err != nil {
+		t.Fatalf("wordpress.xml is not valid XML: %v", err)
+	}
+
+	if len(rss.Items) != 3 {
+		t.Fatalf("want 3 items; have %d", len(rss.Items))
+	}
+
+	post, img := rss.Items[0], rss.Items[2]
+	for _, want := range []string{
+		"<!-- wp:heading {\"level\":4} -->\n<h4 class=\"wp-block-heading\">a poem</h4>\n<!-- /wp:heading -->",
+		"<pre class=\"wp-block-code\"><code>if a ]]&gt; b {}</code></pre>",
+		"<img src=\"https://cdn-images-1.medium.com/owl.png\" alt=\"\"/>",
+	} {
+		if !strings.Contains(post.Content, want) {
+			t.Errorf("post content doesn't contain %s", want)
+		}
+	}
+
+	if img.Type != "attachment" || img.Parent != 1 {
+		t.Errorf("image wasn't attached to the post: %+v", img)
+	}
+
+	if !strings.Contains(string(dat), `<category domain="medium_list" nicename="poems"><![CDATA[Poems]]></category>`) {
+		t.Errorf("post isn't in the Poems list")
+	}
+
+	if strings.Count(string(dat), `<category domain="category" nicename="responses"><![CDATA[Responses]]></category>`) != 1 {
+		t.Errorf("response isn't in the Responses category")
+	}
+}
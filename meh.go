@@ -1,12 +1,17 @@
 package main
 
 import (
+	archive "archive/zip"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/valueof/meh/formatters"
 	"github.com/valueof/meh/parser"
@@ -19,21 +24,50 @@ var VERSION string = "0.3"
 var dir *string
 var zip *string
 var output *string
+var formats formatList
+var frontMatter *string
+var baseURL *string
 var verbose *bool
 var withImages *bool
+var workers *int
+var strict *bool
+var withClaps *bool
 var version *bool
 var server *string
 var logger *log.Logger
 var logbuf bytes.Buffer
 
+// formatList collects values of a repeatable flag. Values can also be
+// separated by commas: -format=json,markdown
+type formatList []string
+
+func (f *formatList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *formatList) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
+	}
+	return nil
+}
+
 func init() {
 	dir = flag.String("dir", "", "path to the uncompressed medium archive")
 	zip = flag.String("zip", "", "path to the compressed medium archive")
 	output = flag.String("out", "", "output directory")
+	flag.Var(&formats, "format", "output format, repeat to get several formats at once: "+strings.Join(formatters.Names(), ", ")+" (default json)")
+	frontMatter = flag.String("frontMatter", "", "front matter format for hugo, jekyll and eleventy: yaml or toml")
+	baseURL = flag.String("baseURL", "", "base URL that post links in feeds should point to")
 	server = flag.String("server", "", "run web version of meh on provided address")
 	verbose = flag.Bool("verbose", false, "whether to print logs to stdout")
 	version = flag.Bool("version", false, "print version and exit")
+	withClaps = flag.Bool("withClaps", false, "whether to include clapped posts in bookmarks")
 	withImages = flag.Bool("withImages", false, "whether to download images from medium cdn")
+	strict = flag.Bool("strict", false, "fail instead of skipping anything that can't be parsed")
+	workers = flag.Int("workers", runtime.NumCPU(), "how many files to parse at the same time")
 	logger = log.New(&logbuf, "meh: ", log.Lmsgprefix)
 }
 
@@ -48,7 +82,7 @@ func run() error {
 	}
 
 	if *server != "" {
-		http.RunHTTPServer(*server)
+		http.RunHTTPServer(*server, VERSION)
 		return nil
 	}
 
@@ -57,54 +91,109 @@ func run() error {
 		return nil
 	}
 
-	input := ""
+	var input fs.FS
 
 	switch {
 	case *zip != "":
-		tmp := filepath.Join(*output, ".archive")
-		err := util.UnzipArchive(*zip, tmp)
+		r, err := archive.OpenReader(*zip)
 		if err != nil {
-			logger.Printf("UnzipArchive(%s, %s): %v", *zip, tmp, err)
+			logger.Printf("zip.OpenReader(%s): %v", *zip, err)
 			return err
 		}
+		defer r.Close()
 
-		defer func() {
-			logger.Printf("clean up: removing %s", tmp)
-			os.RemoveAll(tmp)
-		}()
+		err = util.CheckArchive(&r.Reader)
+		if err != nil {
+			logger.Printf("CheckArchive(%s): %v", *zip, err)
+			return err
+		}
 
-		logger.Printf("extracted archive into %s", tmp)
-		input, err = util.FindArchiveRoot(tmp)
+		input, err = util.FindArchiveRoot(r)
 		if err != nil {
-			logger.Printf("FindArchiveRoot(%s): %v", tmp, err)
+			logger.Printf("FindArchiveRoot(%s): %v", *zip, err)
 			return err
 		}
+
+		logger.Printf("using archive %s as input", *zip)
 	case *dir != "":
-		input = *dir
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			logger.Printf("filepath.Abs(): %v", err)
+			return err
+		}
+
+		input = os.DirFS(abs)
+		logger.Printf("using directory %s as input", abs)
+	}
+
+	if len(formats) == 0 {
+		formats = append(formats, "json")
 	}
 
-	input, err := filepath.Abs(input)
+	w, err := formatters.NewMulti(formats, *output, formatters.Options{
+		FrontMatter: formatters.FrontMatter(*frontMatter),
+		BaseURL:     *baseURL,
+		WithClaps:   *withClaps,
+	}, logger)
 	if err != nil {
-		logger.Printf("filepath.Abs(): %v", err)
+		logger.Printf("%v", err)
 		return err
 	}
 
-	logger.Printf("using directory %s as input", input)
+	// Manifest goes last so it can see files written by all formats
+	w = formatters.NewMultiWriter(w, formatters.NewManifestWriter(*output, VERSION, map[string]any{
+		"dir":         *dir,
+		"zip":         *zip,
+		"format":      []string(formats),
+		"frontMatter": *frontMatter,
+		"baseURL":     *baseURL,
+		"withClaps":   *withClaps,
+		"withImages":  *withImages,
+		"workers":     *workers,
+		"strict":      *strict,
+	}, logger))
+
+	p := parser.NewParser(input, logger, w)
+	p.SetWorkers(*workers)
+	p.SetStrict(*strict)
+	report, err := p.Parse()
+
+	var strictErr *parser.StrictError
+	if errors.As(err, &strictErr) {
+		// Parse didn't write anything, the report explains why
+		formatters.WriteReport(*output, report, logger)
+		for _, d := range strictErr.Diagnostics {
+			fmt.Fprintf(os.Stderr, "meh: %s: %s\n", parser.Location(d), d.Message)
+		}
+	}
 
-	w := formatters.NewJSONFormatter(*output, *logger)
-	p := parser.NewParser(input, *logger, w)
-	err = p.Parse()
 	if err != nil {
 		logger.Printf("parser.Parse(): %v", err)
 		return err
 	}
 
+	err = formatters.WriteReport(*output, report, logger)
+	if err != nil {
+		logger.Printf("formatters.WriteReport(): %v", err)
+		return err
+	}
+
+	if n := len(report.Diagnostics); n > 0 {
+		fmt.Fprintf(os.Stderr, "meh: found %d problems, see %s\n", n, filepath.Join(*output, "report.json"))
+	}
+
 	if *withImages {
 		p.FetchImages(*output)
 	} else {
 		logger.Printf("not downloading images, use -withImages if you want to download images")
 	}
 
+	err = w.Close()
+	if err != nil {
+		logger.Printf("Writer.Close(): %v", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/schema"
+)
+
+// deferredWriter holds on to everything the parser writes until it knows
+// that the output should be written at all. In strict mode a single
+// problem anywhere in the archive means there's no output, so nothing can
+// reach the real writer before the whole archive is parsed.
+type deferredWriter struct {
+	writes []func(formatters.Writer) error
+}
+
+// replay sends all writes to w in the order they were made
+func (d *deferredWriter) replay(w formatters.Writer) error {
+	for _, write := range d.writes {
+		if err := write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deferredWriter) add(write func(formatters.Writer) error) error {
+	d.writes = append(d.writes, write)
+	return nil
+}
+
+func (d *deferredWriter) Begin() error { return nil }
+func (d *deferredWriter) End() error   { return nil }
+func (d *deferredWriter) Close() error { return nil }
+
+func (d *deferredWriter) WritePost(name string, post schema.Post) error {
+	return d.add(func(w formatters.Writer) error { return w.WritePost(name, post) })
+}
+
+func (d *deferredWriter) WriteBlockedUsers(v schema.BlockedUsers) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteBlockedUsers(v) })
+}
+
+func (d *deferredWriter) WriteBookmarks(v schema.Bookmarks) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteBookmarks(v) })
+}
+
+func (d *deferredWriter) WriteClaps(v schema.Claps) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteClaps(v) })
+}
+
+func (d *deferredWriter) WriteHighlights(v schema.Highlights) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteHighlights(v) })
+}
+
+func (d *deferredWriter) WriteInterests(v schema.Interests) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteInterests(v) })
+}
+
+func (d *deferredWriter) WriteIPs(v schema.IPs) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteIPs(v) })
+}
+
+func (d *deferredWriter) WriteLists(v schema.Lists) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteLists(v) })
+}
+
+func (d *deferredWriter) WriteFollowedPublications(v schema.Publications) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteFollowedPublications(v) })
+}
+
+func (d *deferredWriter) WriteFollowedTopics(v schema.Topics) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteFollowedTopics(v) })
+}
+
+func (d *deferredWriter) WriteFollowedUsers(v schema.Users) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteFollowedUsers(v) })
+}
+
+func (d *deferredWriter) WriteSuggestedUsers(v schema.Users) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteSuggestedUsers(v) })
+}
+
+func (d *deferredWriter) WriteSessions(v schema.Sessions) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteSessions(v) })
+}
+
+func (d *deferredWriter) WriteProfile(v schema.Profile) error {
+	return d.add(func(w formatters.Writer) error { return w.WriteProfile(v) })
+}
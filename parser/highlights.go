@@ -8,6 +8,10 @@ import (
 )
 
 func ParseHighlights(dat io.Reader) ([]schema.Highlight, error) {
+	return parseHighlights(dat, nil)
+}
+
+func parseHighlights(dat io.Reader, report util.Reporter) ([]schema.Highlight, error) {
 	doc, err := util.NewNodeFromHTML(dat)
 	if err != nil {
 		return nil, err
@@ -30,7 +34,7 @@ func ParseHighlights(dat io.Reader) ([]schema.Highlight, error) {
 
 		// Node.ParseGrafs ignores non-graf elements so we don't need to do any
 		// additional parsing or stripping here.
-		h.Body = n.ParseGrafs()
+		h.Body = n.ParseGrafs(report)
 		highlights = append(highlights, h)
 	})
 
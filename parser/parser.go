@@ -1,13 +1,15 @@
 package parser
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"io/fs"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
@@ -17,69 +19,224 @@ import (
 )
 
 type Parser struct {
-	logger    log.Logger
-	root      string
-	formatter formatters.Formatter
+	logger  *log.Logger
+	fsys    fs.FS
+	writer  formatters.Writer
+	workers int
+	strict  bool
+	report  schema.Report
 }
 
-func NewParser(root string, logger log.Logger, f formatters.Formatter) *Parser {
+// StrictError is returned by Parse in strict mode when anything in the
+// archive was skipped or not recognized. Diagnostics say what and where.
+type StrictError struct {
+	Diagnostics []schema.Diagnostic
+}
+
+func (e *StrictError) Error() string {
+	d := e.Diagnostics[0]
+	s := fmt.Sprintf("meh: strict mode: %s: %s", Location(d), d.Message)
+	if n := len(e.Diagnostics) - 1; n > 0 {
+		s += fmt.Sprintf(" (and %d more)", n)
+	}
+
+	return s
+}
+
+// Location formats where a diagnostic comes from: a dataset, a file in it
+// and a graf in that file, as much as is known.
+func Location(d schema.Diagnostic) string {
+	loc := d.File
+	if loc == "" {
+		loc = d.Dataset
+	}
+
+	if d.Graf != "" {
+		loc += "#" + d.Graf
+	}
+
+	return loc
+}
+
+// NewParser creates a parser that reads the archive from fsys and sends
+// all parsed data to w. The archive root (where README.html is) must be
+// the root of fsys, see util.FindArchiveRoot. Use formatters.NewWriter to
+// parse into a formatters.Formatter.
+func NewParser(fsys fs.FS, logger *log.Logger, w formatters.Writer) *Parser {
 	return &Parser{
-		logger:    logger,
-		root:      root,
-		formatter: f,
+		logger:  logger,
+		fsys:    fsys,
+		writer:  formatters.NewSyncWriter(w),
+		workers: runtime.NumCPU(),
+	}
+}
+
+// SetWorkers sets how many files can be parsed at the same time. It
+// defaults to the number of CPUs.
+func (p *Parser) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
 	}
+	p.workers = n
+}
+
+// SetStrict turns strict mode on or off. In strict mode Parse returns a
+// StrictError instead of skipping anything it doesn't understand:
+// unsupported directories and files, unknown grafs and markups, and files
+// that failed to parse. Nothing is sent to the writer in that case.
+func (p *Parser) SetStrict(strict bool) {
+	p.strict = strict
 }
 
-func (p *Parser) walk(d fs.FileInfo, fn func(string, io.Reader)) error {
-	dir := filepath.Join(p.root, d.Name())
-	files, err := ioutil.ReadDir(dir)
+// pending is a file that is being parsed by walk
+type pending struct {
+	done        chan func()
+	diagnostics []schema.Diagnostic
+}
+
+// walk parses every HTML file in directory d with fn. Files are parsed
+// concurrently by up to p.workers goroutines, so fn must not touch any
+// shared state. Instead, it can return a function that collects its
+// results: those are called one at a time, in file name order, from the
+// goroutine that called walk. This keeps output the same as if files were
+// parsed one by one.
+//
+// Problems sent to report end up in the parse report, in the same order.
+func (p *Parser) walk(d fs.DirEntry, fn func(string, io.Reader, util.Reporter) func()) error {
+	dir := d.Name()
+	files, err := fs.ReadDir(p.fsys, dir)
 	if err != nil {
-		p.logger.Printf("can't read %s, skipping", path.Base(dir))
+		p.logger.Printf("can't read %s, skipping", dir)
+		p.diagnose(dir, "", failure(err))
 		return err
 	}
 
-	for _, f := range files {
-		if strings.HasSuffix(f.Name(), ".html") == false {
-			p.logger.Printf("%s is not an html file, skipping", f.Name())
-			continue
-		}
+	// Every file gets a channel for its result and those channels are
+	// queued in file order. sem limits how many files can be parsed or
+	// waiting to be collected at the same time.
+	sem := make(chan struct{}, p.workers)
+	queue := make(chan *pending, p.workers)
 
-		dat, err := os.Open(path.Join(dir, f.Name()))
-		if err != nil {
-			p.logger.Printf("can't read %s, skipping", f.Name())
-			continue
+	go func() {
+		defer close(queue)
+
+		for _, f := range files {
+			name := f.Name()
+
+			sem <- struct{}{}
+			file := &pending{done: make(chan func(), 1)}
+			queue <- file
+
+			report := func(v schema.Diagnostic) {
+				v.Dataset = dir
+				v.File = path.Join(dir, name)
+				file.diagnostics = append(file.diagnostics, v)
+			}
+
+			if strings.HasSuffix(name, ".html") == false {
+				p.logger.Printf("%s is not an html file, skipping", name)
+				report(unsupported("%s is not an html file", name))
+				file.done <- nil
+				continue
+			}
+
+			go func() {
+				dat, err := p.fsys.Open(path.Join(dir, name))
+				if err != nil {
+					p.logger.Printf("can't read %s, skipping", name)
+					report(failure(err))
+					file.done <- nil
+					return
+				}
+				defer dat.Close()
+
+				file.done <- fn(name, dat, report)
+			}()
 		}
-		defer dat.Close()
+	}()
 
-		fn(f.Name(), dat)
+	for file := range queue {
+		if collect := <-file.done; collect != nil {
+			collect()
+		}
+		p.report.Diagnostics = append(p.report.Diagnostics, file.diagnostics...)
+		<-sem
 	}
 
 	return nil
 }
 
-func (p *Parser) Parse() error {
-	dirs, err := ioutil.ReadDir(p.root)
+// diagnose adds a problem that isn't tied to a single file to the report
+func (p *Parser) diagnose(dataset, file string, v schema.Diagnostic) {
+	v.Dataset = dataset
+	v.File = file
+	p.report.Diagnostics = append(p.report.Diagnostics, v)
+}
+
+func unsupported(format string, v ...any) schema.Diagnostic {
+	return schema.Diagnostic{
+		Severity: schema.WARNING,
+		Code:     schema.UNSUPPORTED_FILE,
+		Message:  fmt.Sprintf(format, v...),
+	}
+}
+
+func failure(err error) schema.Diagnostic {
+	return schema.Diagnostic{
+		Severity: schema.ERROR,
+		Code:     schema.PARSE_FAILURE,
+		Message:  err.Error(),
+	}
+}
+
+// Parse parses the whole archive and sends it to the writer. It returns a
+// report with every file that was skipped or only partially parsed. In
+// strict mode the report comes with a StrictError if it isn't empty.
+func (p *Parser) Parse() (*schema.Report, error) {
+	p.report = schema.Report{
+		Meta:        "Problems found while parsing your archive",
+		Diagnostics: []schema.Diagnostic{},
+	}
+
+	dirs, err := fs.ReadDir(p.fsys, ".")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// In strict mode nothing is written until the whole archive is parsed
+	// without problems, so a failed run doesn't leave half of the output
+	out := p.writer
+	if p.strict {
+		p.writer = &deferredWriter{}
+		defer func() { p.writer = out }()
+	}
+
+	err = p.writer.Begin()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, d := range dirs {
 		if d.IsDir() == false {
 			p.logger.Printf("%s is not a directory, skipping", d.Name())
+			if d.Name() != "README.html" {
+				p.diagnose("", d.Name(), unsupported("%s is not a directory", d.Name()))
+			}
 			continue
 		}
 
 		switch d.Name() {
 		case "blocks":
 			users := []schema.User{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseBlocked(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				users = append(users, part...)
+				return func() { users = append(users, part...) }
 			})
 
 			if err != nil {
@@ -87,20 +244,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("blocks", schema.BlockedUsers{
+			p.writer.WriteBlockedUsers(schema.BlockedUsers{
 				Meta:  "Blocked users",
 				Users: users,
 			})
 		case "bookmarks":
 			posts := []schema.Post{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseBookmarks(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				posts = append(posts, part...)
+				return func() { posts = append(posts, part...) }
 			})
 
 			if err != nil {
@@ -108,20 +266,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("bookmarks", schema.Bookmarks{
+			p.writer.WriteBookmarks(schema.Bookmarks{
 				Meta:  "Bookmarked posts",
 				Posts: posts,
 			})
 		case "claps":
 			claps := []schema.Clap{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseClaps(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				claps = append(claps, part...)
+				return func() { claps = append(claps, part...) }
 			})
 
 			if err != nil {
@@ -129,7 +288,7 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("claps", schema.Claps{
+			p.writer.WriteClaps(schema.Claps{
 				Meta:  "Posts you've clapped for",
 				Claps: claps,
 			})
@@ -138,43 +297,49 @@ func (p *Parser) Parse() error {
 				Meta: "Topics you're interested in",
 			}
 
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				switch name {
 				case "publications.html":
 					pubs, err := ParseInterestsPublications(dat)
 					if err != nil {
 						p.logger.Printf("error parsing %s, skipping", name)
-						return
+						report(failure(err))
+						return nil
 					}
 					p.logger.Printf("parsed %s", name)
-					interests.Publications = pubs
+					return func() { interests.Publications = pubs }
 				case "tags.html":
 					tags, err := ParseInterestsTags(dat)
 					if err != nil {
 						p.logger.Printf("error parsing %s, skipping", name)
-						return
+						report(failure(err))
+						return nil
 					}
 					p.logger.Printf("parsed %s", name)
-					interests.Tags = tags
+					return func() { interests.Tags = tags }
 				case "topics.html":
 					topics, err := ParseInterestsTopics(dat)
 					if err != nil {
 						p.logger.Printf("error parsing %s, skipping", name)
-						return
+						report(failure(err))
+						return nil
 					}
 					p.logger.Printf("parsed %s", name)
-					interests.Topics = topics
+					return func() { interests.Topics = topics }
 				case "writers.html":
 					writers, err := ParseInterestsWriters(dat)
 					if err != nil {
 						p.logger.Printf("error parsing %s, skipping", name)
-						return
+						report(failure(err))
+						return nil
 					}
 					p.logger.Printf("parsed %s", name)
-					interests.Writers = writers
+					return func() { interests.Writers = writers }
 				default:
 					p.logger.Printf("Unknown interests file %s, skipping", name)
+					report(unsupported("unknown interests file %s", name))
 				}
+				return nil
 			})
 
 			if err != nil {
@@ -182,17 +347,18 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("interests", interests)
+			p.writer.WriteInterests(interests)
 		case "ips":
 			ips := []schema.IP{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseIps(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				ips = append(ips, part...)
+				return func() { ips = append(ips, part...) }
 			})
 
 			if err != nil {
@@ -200,40 +366,45 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("ips", schema.IPs{
+			p.writer.WriteIPs(schema.IPs{
 				Meta: "Your IP history (note: Medium deletes IP history after 30 days)",
 				IPs:  ips,
 			})
 		case "posts":
-			posts := map[string]schema.Post{}
-			err = p.walk(d, func(name string, dat io.Reader) {
-				post, err := ParsePost(dat)
+			// Posts are written as soon as they're parsed so we don't
+			// have to keep all of them in memory.
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
+				post, err := parsePost(dat, report)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				posts[strings.TrimSuffix(name, ".html")] = *post
+
+				// Medium prefixes names of unpublished posts with draft_
+				if strings.HasPrefix(name, "draft_") {
+					post.Status = schema.DRAFT
+				}
+
+				return func() { p.writer.WritePost(strings.TrimSuffix(name, ".html"), *post) }
 			})
 
 			if err != nil {
 				p.logger.Printf("error parsing %s: %v", d.Name(), err)
 				continue
 			}
-
-			for name, post := range posts {
-				p.formatter.WriteFile(filepath.Join("posts", name), post)
-			}
 		case "lists":
 			lists := []schema.List{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				list, err := ParseList(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				lists = append(lists, *list)
+				return func() { lists = append(lists, *list) }
 			})
 
 			if err != nil {
@@ -241,20 +412,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("lists", schema.Lists{
+			p.writer.WriteLists(schema.Lists{
 				Meta:  "Lists you've created",
 				Lists: lists,
 			})
 		case "pubs-following":
 			pubs := []schema.Publication{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParsePublicationFollowing(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				pubs = append(pubs, part...)
+				return func() { pubs = append(pubs, part...) }
 			})
 
 			if err != nil {
@@ -262,20 +434,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("following/publications", schema.Publications{
+			p.writer.WriteFollowedPublications(schema.Publications{
 				Meta:         "Publications you follow",
 				Publications: pubs,
 			})
 		case "topics-following":
 			topics := []schema.Topic{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseTopicsFollowing(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				topics = append(topics, part...)
+				return func() { topics = append(topics, part...) }
 			})
 
 			if err != nil {
@@ -283,20 +456,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("following/topics", schema.Topics{
+			p.writer.WriteFollowedTopics(schema.Topics{
 				Meta:   "Topics you follow",
 				Topics: topics,
 			})
 		case "users-following":
 			users := []schema.User{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseUsersFollowing(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				users = append(users, part...)
+				return func() { users = append(users, part...) }
 			})
 
 			if err != nil {
@@ -304,20 +478,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile(filepath.Join("following", "users"), schema.Users{
+			p.writer.WriteFollowedUsers(schema.Users{
 				Meta:  "Users you follow",
 				Users: users,
 			})
 		case "twitter":
 			users := []schema.User{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseUsersSuggested(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				users = append(users, part...)
+				return func() { users = append(users, part...) }
 			})
 
 			if err != nil {
@@ -325,20 +500,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile(filepath.Join("following", "suggested"), schema.Users{
+			p.writer.WriteSuggestedUsers(schema.Users{
 				Meta:  "Your Twitter friends who are also on Medium",
 				Users: users,
 			})
 		case "sessions":
 			sessions := []schema.Session{}
-			err = p.walk(d, func(name string, dat io.Reader) {
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
 				part, err := ParseSessions(dat)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				sessions = append(sessions, part...)
+				return func() { sessions = append(sessions, part...) }
 			})
 
 			if err != nil {
@@ -346,20 +522,21 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("sessions", schema.Sessions{
+			p.writer.WriteSessions(schema.Sessions{
 				Meta:     "Your active and inactive sessions across devices",
 				Sessions: sessions,
 			})
 		case "highlights":
 			highlights := []schema.Highlight{}
-			err = p.walk(d, func(name string, dat io.Reader) {
-				part, err := ParseHighlights(dat)
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
+				part, err := parseHighlights(dat, report)
 				if err != nil {
 					p.logger.Printf("error parsing %s, skipping", name)
-					return
+					report(failure(err))
+					return nil
 				}
 				p.logger.Printf("parsed %s", name)
-				highlights = append(highlights, part...)
+				return func() { highlights = append(highlights, part...) }
 			})
 
 			if err != nil {
@@ -367,7 +544,7 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("highlights", schema.Highlights{
+			p.writer.WriteHighlights(schema.Highlights{
 				Meta:       "Your highlights",
 				Highlights: highlights,
 			})
@@ -377,47 +554,16 @@ func (p *Parser) Parse() error {
 			}
 			profile.User = &schema.User{}
 
-			err = p.walk(d, func(name string, dat io.Reader) {
-				switch {
-				case name == "about.html":
-					bio, err := ParseBio(dat)
-					if err != nil {
-						p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
-						return
-					}
-					p.logger.Printf("parsed %s", name)
-					profile.User.Bio = bio
-				case name == "profile.html":
-					err = ParseUserProfile(dat, &profile)
-					if err != nil {
-						p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
-						return
-					}
-					p.logger.Printf("parsed %s", name)
-				case name == "publications.html":
-					err = ParsePublications(dat, &profile)
-					if err != nil {
-						p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
-						return
-					}
-					p.logger.Printf("parsed %s", name)
-				case name == "memberships.html":
-					err = ParseMemberships(dat, &profile)
-					if err != nil {
-						p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
-						return
-					}
-					p.logger.Printf("parsed %s", name)
-				case strings.HasPrefix(name, "charges-") && strings.HasSuffix(name, ".html"):
-					err = ParseMembershipCharges(dat, &profile)
-					if err != nil {
-						p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
-						return
-					}
-					p.logger.Printf("parsed %s", name)
-				default:
-					p.logger.Printf("skipped profile/%s: not supported", name)
+			err = p.walk(d, func(name string, dat io.Reader, report util.Reporter) func() {
+				// Profile parsers fill in the same struct so they have
+				// to run one at a time, when results are collected
+				b, err := io.ReadAll(dat)
+				if err != nil {
+					p.logger.Printf("error reading %s, profile.json will be incomplete", name)
+					report(failure(err))
+					return nil
 				}
+				return func() { p.parseProfile(name, bytes.NewReader(b), &profile, report) }
 			})
 
 			if err != nil {
@@ -425,13 +571,83 @@ func (p *Parser) Parse() error {
 				continue
 			}
 
-			p.formatter.WriteFile("profile", profile)
+			p.writer.WriteProfile(profile)
 		default:
 			p.logger.Printf("%s isn't supported, skipping", d.Name())
+			p.diagnose(d.Name(), "", schema.Diagnostic{
+				Severity: schema.WARNING,
+				Code:     schema.UNSUPPORTED_DIR,
+				Message:  fmt.Sprintf("%s isn't supported", d.Name()),
+			})
 		}
 	}
 
-	return nil
+	if p.strict {
+		if len(p.report.Diagnostics) > 0 {
+			return &p.report, &StrictError{Diagnostics: p.report.Diagnostics}
+		}
+
+		err = out.Begin()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.writer.(*deferredWriter).replay(out)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &p.report, out.End()
+}
+
+func (p *Parser) parseProfile(name string, dat io.Reader, profile *schema.Profile, report util.Reporter) {
+	switch {
+	case name == "about.html":
+		bio, err := ParseBio(dat)
+		if err != nil {
+			p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
+			report(failure(err))
+			return
+		}
+		p.logger.Printf("parsed %s", name)
+		profile.User.Bio = bio
+	case name == "profile.html":
+		err := ParseUserProfile(dat, profile, report)
+		if err != nil {
+			p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
+			report(failure(err))
+			return
+		}
+		p.logger.Printf("parsed %s", name)
+	case name == "publications.html":
+		err := ParsePublications(dat, profile)
+		if err != nil {
+			p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
+			report(failure(err))
+			return
+		}
+		p.logger.Printf("parsed %s", name)
+	case name == "memberships.html":
+		err := ParseMemberships(dat, profile)
+		if err != nil {
+			p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
+			report(failure(err))
+			return
+		}
+		p.logger.Printf("parsed %s", name)
+	case strings.HasPrefix(name, "charges-") && strings.HasSuffix(name, ".html"):
+		err := ParseMembershipCharges(dat, profile)
+		if err != nil {
+			p.logger.Printf("error parsing %s, profile.json will be incomplete", name)
+			report(failure(err))
+			return
+		}
+		p.logger.Printf("parsed %s", name)
+	default:
+		p.logger.Printf("skipped profile/%s: not supported", name)
+		report(unsupported("unknown profile file %s", name))
+	}
 }
 
 func (p *Parser) FetchImages(dest string) {
@@ -451,7 +667,9 @@ func (p *Parser) FetchImages(dest string) {
 			err := util.DownloadImage(img, filepath.Join(dir, img))
 			if err != nil {
 				p.logger.Printf("error downloading image %s. err: %v", img, err)
+				return
 			}
+			formatters.RecordOutput(dest, "images/"+img)
 			p.logger.Printf("downloaded %s", img)
 		}()
 	}
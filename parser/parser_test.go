@@ -0,0 +1,250 @@
+package parser_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/valueof/meh/formatters"
+	"github.com/valueof/meh/parser"
+	"github.com/valueof/meh/schema"
+	"github.com/valueof/meh/util"
+)
+
+type clapsWriter struct {
+	formatters.NopWriter
+	claps []schema.Clap
+	begun bool
+	ended bool
+}
+
+func (w *clapsWriter) Begin() error {
+	w.begun = true
+	return nil
+}
+
+func (w *clapsWriter) WriteClaps(v schema.Claps) error {
+	w.claps = v.Claps
+	return nil
+}
+
+func (w *clapsWriter) End() error {
+	w.ended = true
+	return nil
+}
+
+func TestParserZip(t *testing.T) {
+	claps, err := os.ReadFile("../testdata/claps/claps.html")
+	if err != nil {
+		t.Fatalf("no testdata file: %v", err)
+	}
+
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	for name, dat := range map[string][]byte{
+		"medium-export/README.html":      []byte("<html></html>"),
+		"medium-export/claps/claps.html": claps,
+	} {
+		f, _ := z.Create(name)
+		f.Write(dat)
+	}
+	z.Close()
+
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("can't read zip: %v", err)
+	}
+
+	root, err := util.FindArchiveRoot(r)
+	if err != nil {
+		t.Fatalf("FindArchiveRoot(): %v", err)
+	}
+
+	w := &clapsWriter{}
+	_, err = parser.NewParser(root, log.New(io.Discard, "", 0), w).Parse()
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+
+	if len(w.claps) != 3 || w.claps[0].Post.Id != "9e53ca408c48" {
+		t.Errorf("unexpected claps: %+v", w.claps)
+	}
+
+	if !w.ended {
+		t.Errorf("End wasn't called")
+	}
+}
+
+func TestParserMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.html":      {Data: []byte("<html></html>")},
+		"claps/claps.html": {Data: []byte(`<ul><li>+50 — <a class="h-cite u-like-of" href="https://medium.com/p/owls-9e53ca408c48">Owls</a></li></ul>`)},
+		"claps/notes.txt":  {Data: []byte("not html")},
+	}
+
+	w := &clapsWriter{}
+	_, err := parser.NewParser(fsys, log.New(io.Discard, "", 0), w).Parse()
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+
+	if len(w.claps) != 1 || w.claps[0].Amount != 50 {
+		t.Errorf("unexpected claps: %+v", w.claps)
+	}
+}
+
+type orderWriter struct {
+	clapsWriter
+	posts []string
+}
+
+func (w *orderWriter) WritePost(name string, post schema.Post) error {
+	w.posts = append(w.posts, name)
+	return nil
+}
+
+func TestParserWorkers(t *testing.T) {
+	post, err := os.ReadFile("../testdata/posts/basic.html")
+	if err != nil {
+		t.Fatalf("no testdata file: %v", err)
+	}
+
+	fsys := fstest.MapFS{"README.html": {Data: []byte("<html></html>")}}
+	for i := 0; i < 50; i++ {
+		clap := fmt.Sprintf(`<ul><li>+%d — <a class="h-cite u-like-of" href="https://medium.com/p/owls-9e53ca408c48">Owls</a></li></ul>`, i+1)
+		fsys[fmt.Sprintf("claps/claps-%03d.html", i)] = &fstest.MapFile{Data: []byte(clap)}
+		fsys[fmt.Sprintf("posts/post-%03d.html", i)] = &fstest.MapFile{Data: post}
+	}
+
+	w := &orderWriter{}
+	p := parser.NewParser(fsys, log.New(io.Discard, "", 0), w)
+	p.SetWorkers(8)
+
+	_, err = p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+
+	if len(w.claps) != 50 || len(w.posts) != 50 {
+		t.Fatalf("want 50 claps and posts; have %d and %d", len(w.claps), len(w.posts))
+	}
+
+	for i := 0; i < 50; i++ {
+		if w.claps[i].Amount != i+1 {
+			t.Errorf("claps are out of order: %d is %d", i, w.claps[i].Amount)
+		}
+
+		if want := fmt.Sprintf("post-%03d", i); w.posts[i] != want {
+			t.Errorf("posts are out of order: want %s; have %s", want, w.posts[i])
+		}
+	}
+}
+
+func TestParserReport(t *testing.T) {
+	post := `<html><body><section data-field="body"><section name="1901"><div class="section-inner">
+		<p class="graf graf--p" name="a">see <code>meh</code></p>
+		<div class="graf graf--carousel" name="b">spin</div>
+	</div></section></section></body></html>`
+
+	fsys := fstest.MapFS{
+		"README.html":       {Data: []byte("<html></html>")},
+		"posts/basic.html":  {Data: []byte(post)},
+		"podcasts/ep1.html": {Data: []byte("<html></html>")},
+	}
+
+	report, err := parser.NewParser(fsys, log.New(io.Discard, "", 0), &clapsWriter{}).Parse()
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+
+	want := []schema.Diagnostic{
+		{Dataset: "podcasts", Severity: schema.WARNING, Code: schema.UNSUPPORTED_DIR},
+		{File: "posts/basic.html", Dataset: "posts", Graf: "a", Severity: schema.WARNING, Code: schema.UNKNOWN_MARKUP, Snippet: "<code>meh</code>"},
+		{File: "posts/basic.html", Dataset: "posts", Graf: "b", Severity: schema.WARNING, Code: schema.UNKNOWN_GRAF, Snippet: `<div class="graf graf--carousel" name="b">spin</div>`},
+	}
+
+	if len(report.Diagnostics) != len(want) {
+		t.Fatalf("want %d diagnostics; have %+v", len(want), report.Diagnostics)
+	}
+
+	for i, have := range report.Diagnostics {
+		have.Message = ""
+		if have != want[i] {
+			t.Errorf("diagnostic %d:\nwant: %+v\nhave: %+v", i, want[i], have)
+		}
+	}
+}
+
+func TestParserStrict(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.html":      {Data: []byte("<html></html>")},
+		"claps/claps.html": {Data: []byte(`<ul><li>+50 — <a class="h-cite u-like-of" href="https://medium.com/p/owls-9e53ca408c48">Owls</a></li></ul>`)},
+	}
+
+	w := &clapsWriter{}
+	p := parser.NewParser(fsys, log.New(io.Discard, "", 0), w)
+	p.SetStrict(true)
+
+	_, err := p.Parse()
+	if err != nil {
+		t.Fatalf("want no error for a clean archive; have %v", err)
+	}
+
+	if !w.begun || len(w.claps) != 1 || !w.ended {
+		t.Errorf("clean archive wasn't written: %+v", w)
+	}
+
+	fsys["claps/notes.txt"] = &fstest.MapFile{Data: []byte("not html")}
+	fsys["profile/drafts.html"] = &fstest.MapFile{Data: []byte("<html></html>")}
+
+	w = &clapsWriter{}
+	p = parser.NewParser(fsys, log.New(io.Discard, "", 0), w)
+	p.SetStrict(true)
+
+	_, err = p.Parse()
+
+	var strictErr *parser.StrictError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("want StrictError; have %v", err)
+	}
+
+	have := []string{}
+	for _, d := range strictErr.Diagnostics {
+		have = append(have, parser.Location(d))
+	}
+
+	want := []string{"claps/notes.txt", "profile/drafts.html"}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v; have %v", want, have)
+	}
+
+	if w.begun || w.claps != nil || w.ended {
+		t.Errorf("writer was used in spite of errors: %+v", w)
+	}
+
+	// Formatters don't leave anything behind either
+	root := t.TempDir()
+	sw, err := formatters.New("sqlite", root, formatters.Options{}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("can't create formatter: %v", err)
+	}
+
+	p = parser.NewParser(fsys, log.New(io.Discard, "", 0), sw)
+	p.SetStrict(true)
+
+	_, err = p.Parse()
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("want StrictError; have %v", err)
+	}
+
+	if files, _ := os.ReadDir(root); len(files) > 0 {
+		t.Errorf("want no output; have %d files", len(files))
+	}
+}
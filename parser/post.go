@@ -3,30 +3,31 @@ package parser
 import (
 	"io"
 	"strings"
+	"time"
 
 	"github.com/valueof/meh/schema"
 	"github.com/valueof/meh/util"
 )
 
-func parseBody(n *util.Node, post *schema.Post) {
+func parseBody(n *util.Node, post *schema.Post, report util.Reporter) {
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
 		switch {
 		case c.IsElement("section"):
 			post.Content = append(post.Content, schema.Section{
 				Name: c.Attrs["name"],
-				Body: parseInnerSections(c),
+				Body: parseInnerSections(c, report),
 			})
 		}
 	}
 }
 
-func parseInnerSections(body *util.Node) []schema.InnerSection {
+func parseInnerSections(body *util.Node, report util.Reporter) []schema.InnerSection {
 	sections := []schema.InnerSection{}
 
 	var f func(*util.Node)
 	f = func(n *util.Node) {
 		if n.HasClass("section-inner") {
-			grafs := n.ParseGrafs()
+			grafs := n.ParseGrafs(report)
 			if len(grafs) == 0 {
 				return
 			}
@@ -55,9 +56,17 @@ func parseFooter(n *util.Node, post *schema.Post) {
 	switch {
 	case n.IsElement("time") && n.HasClass("dt-published"):
 		post.PublishedAt = n.Attrs["datetime"]
+	case n.IsElement("a") && n.HasClass("p-author"):
+		post.Author = &schema.User{
+			Name:     n.Text(),
+			Username: util.ParseMediumUsername(n.Attrs["href"]),
+			Url:      n.Attrs["href"],
+		}
 	case n.IsElement("a") && n.HasClass("p-canonical"):
 		post.Url = n.Attrs["href"]
 		post.Id = util.ParseMediumId(post.Url)
+	case n.IsElement("p") && strings.HasPrefix(n.Text(), "Exported from"):
+		post.ExportedAt = parseExportDate(n.Text())
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -65,7 +74,63 @@ func parseFooter(n *util.Node, post *schema.Post) {
 	}
 }
 
+// parseInReplyTo looks for the post that a response replies to. Responses
+// link to it with a u-in-reply-to (or p-in-reply-to) h-entry property, or
+// in a paragraph that starts with "In response to". The post body is
+// skipped since it is written by the author and can say anything.
+func parseInReplyTo(doc *util.Node) *schema.Post {
+	var link *util.Node
+	var walk func(*util.Node)
+	walk = func(n *util.Node) {
+		for c := n.FirstChild; c != nil && link == nil; c = c.NextSibling {
+			switch {
+			case c.IsElement("section") && c.Attrs["data-field"] == "body":
+				// Stories can say "In response to" too, only look at metadata
+				continue
+			case c.IsElement("a") && (c.HasClass("u-in-reply-to") || c.HasClass("p-in-reply-to")):
+				link = c
+			case c.IsElement("p") && strings.HasPrefix(c.Text(), "In response to"):
+				link = c.FirstChildElement("a")
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(doc)
+
+	if link == nil || link.Attrs["href"] == "" {
+		return nil
+	}
+
+	return &schema.Post{
+		Id:    util.ParseMediumId(link.Attrs["href"]),
+		Url:   link.Attrs["href"],
+		Title: link.Text(),
+	}
+}
+
+// parseExportDate turns "Exported from Medium on April 5, 2022." into
+// 2022-04-05. Dates in an unexpected format are returned as is.
+func parseExportDate(s string) string {
+	_, date, ok := strings.Cut(s, " on ")
+	if !ok {
+		return ""
+	}
+
+	date = strings.TrimSuffix(strings.TrimSpace(date), ".")
+	t, err := time.Parse("January 2, 2006", date)
+	if err != nil {
+		return date
+	}
+
+	return t.Format("2006-01-02")
+}
+
 func ParsePost(dat io.Reader) (*schema.Post, error) {
+	return parsePost(dat, nil)
+}
+
+func parsePost(dat io.Reader, report util.Reporter) (*schema.Post, error) {
 	doc, err := util.NewNodeFromHTML(dat)
 	if err != nil {
 		return nil, err
@@ -79,8 +144,11 @@ func ParsePost(dat io.Reader) (*schema.Post, error) {
 		case n.IsElement("title"):
 			post.Title = n.Text()
 			return
+		case n.IsElement("section") && n.Attrs["data-field"] == "subtitle":
+			post.Subtitle = n.Text()
+			return
 		case n.IsElement("section") && n.Attrs["data-field"] == "body":
-			parseBody(n, &post)
+			parseBody(n, &post, report)
 		case n.IsElement("footer"):
 			parseFooter(n, &post)
 			return
@@ -92,5 +160,17 @@ func ParsePost(dat io.Reader) (*schema.Post, error) {
 	}
 
 	f(doc)
+
+	// Drafts don't have a publication date in their footer
+	post.Status = schema.PUBLISHED
+	if post.PublishedAt == "" {
+		post.Status = schema.DRAFT
+	}
+
+	post.Kind = schema.STORY
+	if post.InReplyTo = parseInReplyTo(doc); post.InReplyTo != nil {
+		post.Kind = schema.RESPONSE
+	}
+
 	return &post, nil
 }
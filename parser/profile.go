@@ -84,7 +84,7 @@ func parseSocialAccounts(ul *util.Node, p *schema.Profile) {
 	}
 }
 
-func ParseUserProfile(dat io.Reader, profile *schema.Profile) error {
+func ParseUserProfile(dat io.Reader, profile *schema.Profile, report util.Reporter) error {
 	doc, err := util.NewNodeFromHTML(dat)
 	if err != nil {
 		return err
@@ -100,7 +100,7 @@ func ParseUserProfile(dat io.Reader, profile *schema.Profile) error {
 			case c.IsElement("h3") && c.HasClass("p-name"):
 				profile.User.Name = c.Text()
 			case c.IsElement("img") && c.HasClass("u-photo"):
-				profile.User.ProfilePic = c.ExtractImage()
+				profile.User.ProfilePic = c.ExtractImage(report)
 			case c.IsElement("h4") && c.Text() == "Account info":
 				parseAccountInfo(c.NextSiblingElement("ul"), profile)
 			case c.IsElement("h4") && c.Text() == "Connected accounts":
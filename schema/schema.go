@@ -2,6 +2,12 @@ package schema
 
 type MarkupType string
 type GrafType string
+type Severity string
+type DiagnosticCode string
+type PostStatus string
+type PostKind string
+type EmbedProvider string
+type Layout string
 
 const (
 	A         MarkupType = "a"
@@ -22,6 +28,50 @@ const (
 	BLOCKQUOTE GrafType = "bq"
 	EMBED      GrafType = "embed"
 	PRE        GrafType = "pre"
+	OL         GrafType = "ol"
+	UL         GrafType = "ul"
+	LI         GrafType = "li"
+)
+
+const (
+	INSET_CENTER  Layout = "inset-center"
+	INSET_LEFT    Layout = "inset-left"
+	OUTSET_CENTER Layout = "outset-center"
+	OUTSET_LEFT   Layout = "outset-left"
+	FILL_WIDTH    Layout = "fill-width"
+	GRID          Layout = "grid"
+)
+
+const (
+	YOUTUBE EmbedProvider = "youtube"
+	GIST    EmbedProvider = "gist"
+	TWITTER EmbedProvider = "twitter"
+	CODEPEN EmbedProvider = "codepen"
+	LINK    EmbedProvider = "link"
+)
+
+const (
+	DRAFT     PostStatus = "draft"
+	PUBLISHED PostStatus = "published"
+)
+
+const (
+	STORY    PostKind = "story"
+	RESPONSE PostKind = "response"
+)
+
+const (
+	WARNING Severity = "warning"
+	ERROR   Severity = "error"
+)
+
+const (
+	UNKNOWN_GRAF     DiagnosticCode = "unknown-graf"
+	UNKNOWN_MARKUP   DiagnosticCode = "unknown-markup"
+	UNSUPPORTED_DIR  DiagnosticCode = "unsupported-dir"
+	UNSUPPORTED_FILE DiagnosticCode = "unsupported-file"
+	PARSE_FAILURE    DiagnosticCode = "parse-failure"
+	UNSAFE_IMAGE     DiagnosticCode = "unsafe-image"
 )
 
 type BlockedUsers struct {
@@ -44,12 +94,41 @@ type Claps struct {
 	Claps []Clap `json:"claps"`
 }
 
+type Diagnostic struct {
+	File     string         `json:"file,omitempty"`
+	Dataset  string         `json:"dataset,omitempty"`
+	Graf     string         `json:"graf,omitempty"`
+	Severity Severity       `json:"severity"`
+	Code     DiagnosticCode `json:"code"`
+	Message  string         `json:"message"`
+	Snippet  string         `json:"snippet,omitempty"`
+}
+
+// Embed is a link card (Medium calls them mixtapes) or an embedded iframe.
+// Source is a canonical URL, e.g. a YouTube video page rather than its
+// player. Embeds from providers we don't know about are LINK.
+type Embed struct {
+	Provider    EmbedProvider `json:"provider"`
+	Source      string        `json:"source"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Thumbnail   *Image        `json:"thumbnail,omitempty"`
+	Width       string        `json:"width,omitempty"`
+	Height      string        `json:"height,omitempty"`
+}
+
+// Graf is a single block of a post. Figures keep every image in Images,
+// Image is the first one of them, and their caption in Text and Markups.
 type Graf struct {
 	Type    GrafType `json:"type"`
 	Name    string   `json:"name"`
 	Text    string   `json:"text,omitempty"`
 	Image   *Image   `json:"image,omitempty"`
+	Images  []Image  `json:"images,omitempty"`
+	Layout  Layout   `json:"layout,omitempty"`
 	Markups []Markup `json:"markups"`
+	Items   []Graf   `json:"items,omitempty"`
+	Embed   *Embed   `json:"embed,omitempty"`
 }
 
 type Highlight struct {
@@ -104,6 +183,22 @@ type Lists struct {
 	Lists []List `json:"list"`
 }
 
+type Manifest struct {
+	Version    string         `json:"version"`
+	StartedAt  string         `json:"startedAt"`
+	FinishedAt string         `json:"finishedAt"`
+	ExportedAt string         `json:"exportedAt,omitempty"`
+	Options    map[string]any `json:"options"`
+	Counts     map[string]int `json:"counts"`
+	Files      []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
 type Markup struct {
 	Type  MarkupType `json:"type"`
 	Start int        `json:"start"`
@@ -125,11 +220,30 @@ type MembershipCharge struct {
 }
 
 type Post struct {
-	Id          string    `json:"id"`
-	Url         string    `json:"url"`
-	Title       string    `json:"title"`
-	PublishedAt string    `json:"publishedAt,omitempty"`
-	Content     []Section `json:"content,omitempty"`
+	Id          string     `json:"id"`
+	Url         string     `json:"url"`
+	Title       string     `json:"title"`
+	Subtitle    string     `json:"subtitle,omitempty"`
+	Author      *User      `json:"author,omitempty"`
+	Status      PostStatus `json:"status,omitempty"`
+	Kind        PostKind   `json:"kind,omitempty"`
+	InReplyTo   *Post      `json:"inReplyTo,omitempty"`
+	PublishedAt string     `json:"publishedAt,omitempty"`
+	ExportedAt  string     `json:"exportedAt,omitempty"`
+	Content     []Section  `json:"content,omitempty"`
+}
+
+type PostIndex struct {
+	Meta  string           `json:"meta,omitempty"`
+	Posts []PostIndexEntry `json:"posts"`
+}
+
+type PostIndexEntry struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	PublishedAt string `json:"publishedAt,omitempty"`
+	Url         string `json:"url,omitempty"`
+	InReplyTo   *Post  `json:"inReplyTo,omitempty"`
 }
 
 type Profile struct {
@@ -154,6 +268,11 @@ type Publications struct {
 	Publications []Publication `json:"publications"`
 }
 
+type Report struct {
+	Meta        string       `json:"meta,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
 type Section struct {
 	Name string         `json:"name"`
 	Body []InnerSection `json:"body"`
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/valueof/meh/schema"
 	"github.com/valueof/meh/util"
 )
 
@@ -20,6 +21,85 @@ type pageMeta struct {
 	Refresh    string
 }
 
+type formatOption struct {
+	Name     string
+	Label    string
+	Selected bool
+}
+
+type homePageData struct {
+	Formats []formatOption
+	pageMeta
+}
+
+type reportLine struct {
+	Count int
+	Label string
+}
+
+type fetchPageData struct {
+	Download string
+	Problems []reportLine
+	pageMeta
+}
+
+// reportLabels describe diagnostic codes on the result page, in the order
+// they are listed there
+var reportLabels = []struct {
+	Code schema.DiagnosticCode
+	One  string
+	Many string
+}{
+	{schema.PARSE_FAILURE, "file we couldn’t read", "files we couldn’t read"},
+	{schema.UNSUPPORTED_DIR, "folder we don’t support yet", "folders we don’t support yet"},
+	{schema.UNSUPPORTED_FILE, "file we don’t support yet", "files we don’t support yet"},
+	{schema.UNKNOWN_GRAF, "paragraph or block we didn’t recognize", "paragraphs or blocks we didn’t recognize"},
+	{schema.UNKNOWN_MARKUP, "bit of formatting we didn’t recognize", "bits of formatting we didn’t recognize"},
+}
+
+// summarizeReport counts problems in a parse report by their code
+func summarizeReport(report *schema.Report) []reportLine {
+	if report == nil {
+		return nil
+	}
+
+	counts := map[schema.DiagnosticCode]int{}
+	for _, d := range report.Diagnostics {
+		counts[d.Code]++
+	}
+
+	lines := []reportLine{}
+	for _, l := range reportLabels {
+		switch n := counts[l.Code]; {
+		case n == 1:
+			lines = append(lines, reportLine{Count: n, Label: l.One})
+		case n > 1:
+			lines = append(lines, reportLine{Count: n, Label: l.Many})
+		}
+	}
+
+	return lines
+}
+
+// Output formats offered on the web form, JSON is selected by default
+var webFormats = []formatOption{
+	{Name: "json", Label: "JSON", Selected: true},
+	{Name: "markdown", Label: "Markdown"},
+	{Name: "html", Label: "HTML pages"},
+	{Name: "epub", Label: "EPUB e-book"},
+	{Name: "feed", Label: "Atom and RSS feeds"},
+	{Name: "bookmarks", Label: "Bookmarks (browsers, Pocket, Instapaper, Raindrop)"},
+	{Name: "opml", Label: "OPML subscriptions"},
+	{Name: "wordpress", Label: "WordPress"},
+	{Name: "ghost", Label: "Ghost"},
+	{Name: "hugo", Label: "Hugo"},
+	{Name: "jekyll", Label: "Jekyll"},
+	{Name: "eleventy", Label: "Eleventy"},
+	{Name: "sqlite", Label: "SQLite"},
+	{Name: "csv", Label: "CSV"},
+	{Name: "jsonl", Label: "JSON Lines"},
+}
+
 type errorPageData struct {
 	RequestID    string
 	ErrorMessage string
@@ -59,7 +139,11 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render(w, r, "home.html", pageMeta{Title: "Medium Export Helper"})
+	data := homePageData{}
+	data.Title = "Medium Export Helper"
+	data.Formats = webFormats
+
+	render(w, r, "home.html", data)
 }
 
 func upload(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +163,24 @@ func upload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	withImages := len(r.MultipartForm.Value["withImages"]) > 0
+	formats := r.MultipartForm.Value["format"]
+	if len(formats) == 0 {
+		formats = []string{"json"}
+	}
+
+	for _, f := range formats {
+		known := false
+		for _, o := range webFormats {
+			known = known || o.Name == f
+		}
+
+		if !known {
+			logger.Printf("unknown format %s was sent from the client", f)
+			serverError(w, r, "We don’t support one of the formats you picked")
+			return
+		}
+	}
+
 	uploads := r.MultipartForm.File["archive"]
 	if len(uploads) == 0 {
 		logger.Printf("no file was sent from the client")
@@ -138,7 +240,7 @@ func upload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Printf("Uploaded %s", dest)
-	go unzipAndParse(receipt, withImages, logger)
+	go unzipAndParse(receipt, withImages, formats, logger)
 
 	url := fmt.Sprintf("/result/%s", receipt)
 	http.Redirect(w, r, url, http.StatusFound)
@@ -190,10 +292,23 @@ func result(w http.ResponseWriter, r *http.Request) {
 
 	switch st {
 	case TaskDone:
-		render(w, r, "fetch.html", pageMeta{
-			Title:      "[meh] Downloading...",
-			SkipFooter: true,
-			Refresh:    fmt.Sprintf("0;url=/result/%s/?dl", receipt),
+		download := fmt.Sprintf("/result/%s/?dl", receipt)
+		problems := tasks.Problems(receipt)
+
+		// Give people a moment to read about skipped data
+		delay := 0
+		if len(problems) > 0 {
+			delay = 10
+		}
+
+		render(w, r, "fetch.html", fetchPageData{
+			Download: download,
+			Problems: problems,
+			pageMeta: pageMeta{
+				Title:      "[meh] Downloading...",
+				SkipFooter: true,
+				Refresh:    fmt.Sprintf("%d;url=%s", delay, download),
+			},
 		})
 	case TaskErrUnknown:
 		internalServerError(w, r)
@@ -203,6 +318,9 @@ func result(w http.ResponseWriter, r *http.Request) {
 	case TaskErrArchiveFormat:
 		serverError(w, r, "The file we received wasn’t a valid Medium archive")
 		go cleanup(receipt, logger)
+	case TaskErrUnsafeArchive:
+		serverError(w, r, "The file we received is too large or contains files we can’t accept")
+		go cleanup(receipt, logger)
 	default:
 		render(w, r, "wait.html", pageMeta{
 			Title:      "[meh] Converting...",
@@ -26,6 +26,9 @@ var templates embed.FS
 
 var tasks TaskPool
 
+// version of meh that runs the server, recorded in manifests
+var version string
+
 func render(w http.ResponseWriter, r *http.Request, name string, data any) {
 	ctx := r.Context()
 	logger := getLoggerFromContext(ctx)
@@ -86,7 +89,9 @@ func logging(logger *log.Logger) func(http.Handler) http.Handler {
 	}
 }
 
-func RunHTTPServer(addr string) {
+func RunHTTPServer(addr, v string) {
+	version = v
+
 	logger := log.New(os.Stdout, "server: ", log.LstdFlags)
 	logger.Println("Server is starting...")
 
@@ -98,7 +103,7 @@ func RunHTTPServer(addr string) {
 	}
 
 	logger.Println("Creating task pool")
-	tasks = TaskPool{pool: make(map[string]taskStatus)}
+	tasks = TaskPool{pool: make(map[string]taskStatus), problems: make(map[string][]reportLine)}
 
 	router := http.NewServeMux()
 	router.HandleFunc("/", homepage)
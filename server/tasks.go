@@ -10,6 +10,7 @@ import (
 
 	"github.com/valueof/meh/formatters"
 	"github.com/valueof/meh/parser"
+	"github.com/valueof/meh/schema"
 	"github.com/valueof/meh/util"
 )
 
@@ -21,11 +22,13 @@ const (
 	TaskErrUnknown       taskStatus = 3
 	TaskErrZipFormat     taskStatus = 4
 	TaskErrArchiveFormat taskStatus = 5
+	TaskErrUnsafeArchive taskStatus = 6
 )
 
 type TaskPool struct {
-	mu   sync.Mutex
-	pool map[string]taskStatus
+	mu       sync.Mutex
+	pool     map[string]taskStatus
+	problems map[string][]reportLine
 }
 
 func (t *TaskPool) Create(receipt string) {
@@ -46,28 +49,50 @@ func (t *TaskPool) Status(receipt string) (taskStatus, bool) {
 	return 0, false
 }
 
-func (t *TaskPool) Complete(receipt string) error {
+// Complete marks a task as done. Only a summary of its parse report is
+// kept, the full report is in the archive people download.
+func (t *TaskPool) Complete(receipt string, report *schema.Report) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	if _, ok := t.pool[receipt]; ok {
 		t.pool[receipt] = TaskDone
+		t.problems[receipt] = summarizeReport(report)
 		return nil
 	}
 
 	return errors.New("can't complete task that doesn't exist")
 }
 
+// Problems returns a summary of the parse report of a completed task
+func (t *TaskPool) Problems(receipt string) []reportLine {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.problems[receipt]
+}
+
+// Forget drops the summary of a task once its files are cleaned up
+func (t *TaskPool) Forget(receipt string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.problems, receipt)
+}
+
 func (t *TaskPool) Error(receipt string, e error) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	if _, ok := t.pool[receipt]; ok {
-		switch e {
-		case zip.ErrFormat:
+		var uae *util.UnsafeArchiveError
+		switch {
+		case e == zip.ErrFormat:
 			t.pool[receipt] = TaskErrZipFormat
-		case util.ErrArchiveRootNotFound:
+		case e == util.ErrArchiveRootNotFound:
 			t.pool[receipt] = TaskErrArchiveFormat
+		case errors.As(e, &uae):
+			t.pool[receipt] = TaskErrUnsafeArchive
 		default:
 			t.pool[receipt] = TaskErrUnknown
 		}
@@ -78,46 +103,68 @@ func (t *TaskPool) Error(receipt string, e error) error {
 	return errors.New("can't error task that doesn't exist")
 }
 
-func unzipAndParse(receipt string, withImages bool, logger *log.Logger) {
+func unzipAndParse(receipt string, withImages bool, formats []string, logger *log.Logger) {
 	tasks.Create(receipt)
 
-	zip := filepath.Join(INBOUND_DIR, receipt, "upload.zip")
-	tmp := filepath.Join(INBOUND_DIR, receipt, ".upload")
-	err := util.UnzipArchive(zip, tmp)
+	upload := filepath.Join(INBOUND_DIR, receipt, "upload.zip")
+	defer func() {
+		logger.Printf("clean up: removing %s", upload)
+		os.RemoveAll(upload)
+	}()
+
+	r, err := zip.OpenReader(upload)
 	if err != nil {
-		logger.Printf("UnzipArchive(%s, %s): %v", zip, tmp, err)
+		logger.Printf("zip.OpenReader(%s): %v", upload, err)
 		tasks.Error(receipt, err)
 		return
 	}
+	defer r.Close()
 
-	defer func() {
-		logger.Printf("clean up: removing %s", zip)
-		os.RemoveAll(zip)
+	err = util.CheckArchive(&r.Reader)
+	if err != nil {
+		logger.Printf("util.CheckArchive(%s): %v", upload, err)
+		tasks.Error(receipt, err)
+		return
+	}
+
+	input, err := util.FindArchiveRoot(r)
+	if err != nil {
+		logger.Printf("util.FindArchiveRoot(%s): %v", upload, err)
+		tasks.Error(receipt, err)
+		return
+	}
 
-		logger.Printf("clean up: removing %s", tmp)
-		os.RemoveAll(tmp)
+	output := filepath.Join(INBOUND_DIR, receipt, ".output")
+	defer func() {
+		logger.Printf("clean up: removing %s", output)
+		os.RemoveAll(output)
+		formatters.ForgetOutputs(output)
 	}()
 
-	root, err := util.FindArchiveRoot(tmp)
+	w, err := formatters.NewMulti(formats, output, formatters.Options{}, logger)
 	if err != nil {
-		logger.Printf("util.FindArchiveRoot(%s): %v", tmp, err)
+		logger.Printf("formatters.NewMulti(%v): %v", formats, err)
 		tasks.Error(receipt, err)
 		return
 	}
 
-	input, err := filepath.Abs(root)
+	// Manifest goes last so it can see files written by all formats
+	w = formatters.NewMultiWriter(w, formatters.NewManifestWriter(output, version, map[string]any{
+		"format":     formats,
+		"withImages": withImages,
+	}, logger))
+
+	p := parser.NewParser(input, logger, w)
+	report, err := p.Parse()
 	if err != nil {
-		logger.Printf("filepath.Abs(): %v", err)
+		logger.Printf("parser.Parse(): %v", err)
 		tasks.Error(receipt, err)
 		return
 	}
 
-	output := filepath.Join(INBOUND_DIR, receipt, ".output")
-	w := formatters.NewJSONFormatter(output, *logger)
-	p := parser.NewParser(input, *logger, w)
-	err = p.Parse()
+	err = formatters.WriteReport(output, report, logger)
 	if err != nil {
-		logger.Printf("parser.Parse(): %v", err)
+		logger.Printf("formatters.WriteReport(): %v", err)
 		tasks.Error(receipt, err)
 		return
 	}
@@ -126,10 +173,12 @@ func unzipAndParse(receipt string, withImages bool, logger *log.Logger) {
 		p.FetchImages(output)
 	}
 
-	defer func() {
-		logger.Printf("clean up: removing %s", output)
-		os.RemoveAll(output)
-	}()
+	err = w.Close()
+	if err != nil {
+		logger.Printf("Writer.Close(): %v", err)
+		tasks.Error(receipt, err)
+		return
+	}
 
 	outzip := filepath.Join(INBOUND_DIR, receipt, "output.zip")
 	err = util.ZipArchive(output, outzip)
@@ -139,10 +188,12 @@ func unzipAndParse(receipt string, withImages bool, logger *log.Logger) {
 		return
 	}
 
-	tasks.Complete(receipt)
+	tasks.Complete(receipt, report)
 }
 
 func cleanup(receipt string, logger *log.Logger) {
+	tasks.Forget(receipt)
+
 	dir := filepath.Join(INBOUND_DIR, receipt)
 	logger.Printf("Cleaning up %s", dir)
 
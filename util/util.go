@@ -6,25 +6,35 @@ package util
 
 import (
 	"archive/zip"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/valueof/meh/schema"
 	"golang.org/x/net/html"
 )
 
 var SPACE_RE *regexp.Regexp = regexp.MustCompile(`\s+`)
+var BACKGROUND_URL_RE *regexp.Regexp = regexp.MustCompile(`url\(['"]?([^'")]+)['"]?\)`)
 var DL_QUEUE map[string]bool
 
+// dlMu guards DL_QUEUE, images are queued from concurrent parsers
+var dlMu sync.Mutex
+
 var (
 	ErrArchiveRootNotFound = errors.New("meh: archive root not found")
 )
@@ -35,15 +45,25 @@ func init() {
 
 // GetQueuedImages returns a slice of image IDs that need to be downloaded
 func GetQueuedImages() (q []string) {
+	dlMu.Lock()
+	defer dlMu.Unlock()
+
 	q = []string{}
 
 	for id := range DL_QUEUE {
 		q = append(q, id)
 	}
 
+	sort.Strings(q)
 	return
 }
 
+// ValidImageName reports whether name can be used as a file name in the
+// images directory: a single path element that isn't "." or "..".
+func ValidImageName(name string) bool {
+	return name != "" && name != "." && name != ".." && path.Base(name) == name && !strings.Contains(name, `\`)
+}
+
 // DownloadImage downloads an image from Medium CDN and saves it into
 // a directory specified by dest.
 func DownloadImage(img, dest string) error {
@@ -94,6 +114,56 @@ func ParseMediumId(s string) string {
 	return ""
 }
 
+// ParseMediumSlug Parses post slug out of a Medium URL. Slug is the part of
+// the last path segment that comes before the post ID:
+// 	https://medium.com/@anton/oh-right-70c5683f3778 -> oh-right
+// 	https://medium.com/p/5940ded906e7              -> ""
+func ParseMediumSlug(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+
+	id := ParseMediumId(s)
+	p := strings.Split(strings.TrimSuffix(u.Path, "/"), "/")
+	last := p[len(p)-1]
+	if id == "" || !strings.HasSuffix(last, "-"+id) {
+		return ""
+	}
+
+	return strings.TrimSuffix(last, "-"+id)
+}
+
+// MediumFeedUrl Builds a Medium RSS feed URL out of a link to a user,
+// publication, tag or topic:
+// 	https://medium.com/@anton          -> https://medium.com/feed/@anton
+// 	https://medium.com/wildlife-trekker -> https://medium.com/feed/wildlife-trekker
+// 	https://medium.com/tag/birds       -> https://medium.com/feed/tag/birds
+// 	https://blog.medium.com            -> https://blog.medium.com/feed
+//
+// Medium doesn't have feeds for topics so they are mapped to tags with the
+// same name.
+func MediumFeedUrl(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	if u.Host != "medium.com" && u.Host != "www.medium.com" {
+		return "https://" + u.Host + "/feed"
+	}
+
+	p := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case p[0] == "":
+		return ""
+	case (p[0] == "tag" || p[0] == "topic") && len(p) > 1:
+		return "https://medium.com/feed/tag/" + p[1]
+	default:
+		return "https://medium.com/feed/" + p[0]
+	}
+}
+
 // ParseMediumUsername Parses username out of a Medium URL. For now it only
 // supports medium.com/@username and username.medium.com.
 //
@@ -225,9 +295,64 @@ func (n *Node) TextPreformatted() string {
 	return strings.Join(s, "")
 }
 
+// Snippets longer than this are cut off
+const snippetLen = 200
+
+// Reporter receives problems found while parsing, such as unknown grafs or
+// markups. Callers fill in File and Dataset since Nodes don't know where
+// they came from. A nil Reporter discards everything.
+type Reporter func(schema.Diagnostic)
+
+func (r Reporter) warn(code schema.DiagnosticCode, n *Node, format string, v ...any) {
+	if r == nil {
+		return
+	}
+
+	r(schema.Diagnostic{
+		Severity: schema.WARNING,
+		Code:     code,
+		Message:  fmt.Sprintf(format, v...),
+		Snippet:  n.Snippet(),
+	})
+}
+
+// forGraf marks everything sent to r with the name of a graf it came from
+func (r Reporter) forGraf(name string) Reporter {
+	if r == nil {
+		return nil
+	}
+
+	return func(d schema.Diagnostic) {
+		d.Graf = name
+		r(d)
+	}
+}
+
+// Snippet returns a short piece of HTML source of a given Node to help
+// figure out what went wrong while parsing it
+func (n *Node) Snippet() string {
+	var b bytes.Buffer
+	err := html.Render(&b, n.Node)
+	if err != nil {
+		return ""
+	}
+
+	s := strings.TrimSpace(collapseSpace(b.String()))
+	if len(s) <= snippetLen {
+		return s
+	}
+
+	s = s[:snippetLen]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+
+	return s + "…"
+}
+
 // Markup returns a stacked slice of schema.Markup for the giving Node
 // relative (and applicable to) the output of Text()
-func (n *Node) Markup() (markup []schema.Markup) {
+func (n *Node) Markup(report Reporter) (markup []schema.Markup) {
 	s := ""
 	markup = []schema.Markup{}
 
@@ -267,7 +392,7 @@ func (n *Node) Markup() (markup []schema.Markup) {
 				markup = append(markup, fn(schema.HIGHLIGHT))
 			}
 		default:
-			fmt.Printf("Unknown markup: %s; %s\n", t.Data, t.Text())
+			report.warn(schema.UNKNOWN_MARKUP, t, "unknown markup: %s", t.Data)
 		}
 	})
 
@@ -275,7 +400,7 @@ func (n *Node) Markup() (markup []schema.Markup) {
 }
 
 // Extract extracts image metadata from a given Node
-func (n *Node) ExtractImage() (img *schema.Image) {
+func (n *Node) ExtractImage(report Reporter) (img *schema.Image) {
 	var c *Node
 	if n.IsElement("img") {
 		c = n
@@ -287,33 +412,215 @@ func (n *Node) ExtractImage() (img *schema.Image) {
 		return nil
 	}
 
+	return c.image(report)
+}
+
+// ExtractImages extracts every image in a figure in document order, so
+// grids keep all of their images.
+func (n *Node) ExtractImages(report Reporter) []schema.Image {
+	images := []schema.Image{}
+	n.WalkChildren(func(c *Node) {
+		if c.IsElement("img") {
+			images = append(images, *c.image(report))
+		}
+	})
+	return images
+}
+
+// image queues an img element for download and returns its description.
+// Images with names that aren't safe to use as file names keep their
+// source but are neither named nor queued.
+func (n *Node) image(report Reporter) *schema.Image {
 	name := ""
-	if c.Attrs["data-image-id"] != "" {
-		name = c.Attrs["data-image-id"]
+	if n.Attrs["data-image-id"] != "" {
+		name = n.Attrs["data-image-id"]
 	} else {
-		u, err := url.Parse(c.Attrs["src"])
+		u, err := url.Parse(n.Attrs["src"])
 		if err == nil {
 			p := strings.Split(u.Path, "/")
 			name = p[len(p)-1]
 		}
 	}
 
+	if !ValidImageName(name) {
+		if name != "" {
+			report.warn(schema.UNSAFE_IMAGE, n, "image name isn't a file name: %q", name)
+		}
+		name = ""
+	}
+
 	// Queue image for download
-	DL_QUEUE[name] = true
+	if name != "" {
+		dlMu.Lock()
+		DL_QUEUE[name] = true
+		dlMu.Unlock()
+	}
 
 	return &schema.Image{
 		Name:   name,
-		Width:  c.Attrs["data-width"],
-		Height: c.Attrs["data-height"],
-		Source: c.Attrs["src"],
+		Width:  n.Attrs["data-width"],
+		Height: n.Attrs["data-height"],
+		Source: n.Attrs["src"],
+		Alt:    n.Attrs["alt"],
 	}
 }
 
+// Layout returns layout of a figure. Medium puts images side by side either
+// in one figure or in a row of figures that continue each other.
+func (n *Node) Layout() schema.Layout {
+	switch {
+	case n.HasClass("graf--layoutOutsetRow"), n.HasClass("graf--layoutOutsetRowContinue"):
+		return schema.GRID
+	case n.HasClass("graf--layoutOutsetCenter"):
+		return schema.OUTSET_CENTER
+	case n.HasClass("graf--layoutOutsetLeft"):
+		return schema.OUTSET_LEFT
+	case n.HasClass("graf--layoutFillWidth"):
+		return schema.FILL_WIDTH
+	case n.HasClass("graf--layoutInsetLeft"):
+		return schema.INSET_LEFT
+	case n.HasClass("graf--layoutInsetCenter"):
+		return schema.INSET_CENTER
+	}
+	return ""
+}
+
+// ExtractMixtape extracts a link card: its URL, title, description and
+// thumbnail. Thumbnails are queued for download like any other image.
+func (n *Node) ExtractMixtape(report Reporter) *schema.Embed {
+	embed := &schema.Embed{}
+
+	n.WalkChildren(func(c *Node) {
+		switch {
+		case c.IsElement("a") && c.HasClass("js-mixtapeImage"):
+			name := c.Attrs["data-thumbnail-img-id"]
+			if name == "" || embed.Thumbnail != nil {
+				return
+			}
+
+			if !ValidImageName(name) {
+				report.warn(schema.UNSAFE_IMAGE, c, "thumbnail name isn't a file name: %q", name)
+				return
+			}
+
+			dlMu.Lock()
+			DL_QUEUE[name] = true
+			dlMu.Unlock()
+
+			embed.Thumbnail = &schema.Image{Name: name}
+			if m := BACKGROUND_URL_RE.FindStringSubmatch(c.Attrs["style"]); m != nil {
+				embed.Thumbnail.Source = m[1]
+			}
+		case c.IsElement("a") && embed.Source == "":
+			embed.Source = c.Attrs["href"]
+		case c.IsElement("strong") && embed.Title == "":
+			embed.Title = c.Text()
+		case c.IsElement("em") && embed.Description == "":
+			embed.Description = c.Text()
+		}
+	})
+
+	if embed.Source == "" {
+		return nil
+	}
+
+	embed.Provider, embed.Source = ParseEmbedSource(embed.Source)
+	return embed
+}
+
+// ExtractIframe extracts an embedded iframe, falling back to links and
+// scripts since Medium exports some embeds (tweets, gists) without one.
+func (n *Node) ExtractIframe() *schema.Embed {
+	var iframe, link, script *Node
+	n.WalkChildren(func(c *Node) {
+		switch {
+		case c.IsElement("iframe") && iframe == nil:
+			iframe = c
+		case c.IsElement("a") && link == nil && c.Attrs["href"] != "":
+			link = c
+		case c.IsElement("script") && script == nil && c.Attrs["src"] != "":
+			script = c
+		}
+	})
+
+	embed := &schema.Embed{}
+	switch {
+	case iframe != nil && iframe.Attrs["src"] != "":
+		embed.Source = iframe.Attrs["src"]
+		embed.Width = iframe.Attrs["width"]
+		embed.Height = iframe.Attrs["height"]
+	case link != nil:
+		embed.Source = link.Attrs["href"]
+	case script != nil:
+		embed.Source = script.Attrs["src"]
+	default:
+		return nil
+	}
+
+	embed.Provider, embed.Source = ParseEmbedSource(embed.Source)
+	return embed
+}
+
+// ParseEmbedSource figures out who provides an embed and turns player and
+// script URLs into canonical ones.
+//
+// Examples:
+//
+//	In:  https://www.youtube.com/embed/dQw4w9WgXcQ?feature=oembed
+//	Out: youtube, https://www.youtube.com/watch?v=dQw4w9WgXcQ
+//
+//	In:  https://gist.github.com/anton/8d2c1e8f.js
+//	Out: gist, https://gist.github.com/anton/8d2c1e8f
+func ParseEmbedSource(s string) (schema.EmbedProvider, string) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return schema.LINK, s
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	p := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtube.com", "youtube-nocookie.com", "m.youtube.com":
+		if len(p) == 2 && p[0] == "embed" {
+			return schema.YOUTUBE, "https://www.youtube.com/watch?v=" + p[1]
+		}
+		return schema.YOUTUBE, s
+	case "youtu.be":
+		return schema.YOUTUBE, "https://www.youtube.com/watch?v=" + p[0]
+	case "gist.github.com":
+		return schema.GIST, "https://gist.github.com/" + strings.TrimSuffix(strings.Join(p, "/"), ".js")
+	case "twitter.com", "mobile.twitter.com", "x.com":
+		return schema.TWITTER, s
+	case "platform.twitter.com":
+		if id := u.Query().Get("id"); id != "" {
+			return schema.TWITTER, "https://twitter.com/i/status/" + id
+		}
+		return schema.TWITTER, s
+	case "codepen.io":
+		// https://codepen.io/anton/embed/preview/abcd
+		if len(p) >= 3 && p[1] == "embed" {
+			return schema.CODEPEN, "https://codepen.io/" + p[0] + "/pen/" + p[len(p)-1]
+		}
+		return schema.CODEPEN, s
+	}
+
+	return schema.LINK, s
+}
+
 // ParseGrafs parses a give Node and extracts all grafs, together with their markups.
-func (n *Node) ParseGrafs() []schema.Graf {
+// Unknown grafs and markups are skipped and sent to report.
+func (n *Node) ParseGrafs(report Reporter) []schema.Graf {
 	grafs := []schema.Graf{}
 
 	for g := n.FirstChild; g != nil; g = g.NextSibling {
+		if g.IsElement("ol") || g.IsElement("ul") {
+			if list := g.parseList(report); len(list.Items) > 0 {
+				grafs = append(grafs, list)
+			}
+			continue
+		}
+
 		if !g.HasClass("graf") {
 			continue
 		}
@@ -322,48 +629,71 @@ func (n *Node) ParseGrafs() []schema.Graf {
 			Name:    g.Attrs["name"],
 			Markups: []schema.Markup{},
 		}
+		report := report.forGraf(graf.Name)
 
 		switch {
 		case g.HasClass("graf--h1"):
 			graf.Type = schema.H1
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
 		case g.HasClass("graf--h2"):
 			graf.Type = schema.H2
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
 		case g.HasClass("graf--h3"):
 			graf.Type = schema.H3
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
 		case g.HasClass("graf--h4"):
 			graf.Type = schema.H4
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
 		case g.HasClass("graf--blockquote"):
 			fallthrough
 		case g.HasClass("graf--pullquote"):
 			graf.Type = schema.BLOCKQUOTE
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
 		case g.HasClass("graf--p"):
 			graf.Type = schema.P
 			graf.Text = g.Text()
-			graf.Markups = g.Markup()
+			graf.Markups = g.Markup(report)
+		case g.HasClass("graf--iframe"):
+			graf.Type = schema.EMBED
+			graf.Embed = g.ExtractIframe()
+			if c := g.FirstChildElement("figcaption"); c != nil {
+				graf.Text = c.Text()
+				graf.Markups = c.Markup(report)
+			}
+			if graf.Embed == nil {
+				report.warn(schema.UNKNOWN_GRAF, g, "embed without a source")
+				graf.Type = ""
+			}
 		case g.HasClass("graf--figure"):
 			graf.Type = schema.IMG
-			graf.Image = g.ExtractImage()
+			graf.Images = g.ExtractImages(report)
+			if len(graf.Images) > 0 {
+				graf.Image = &graf.Images[0]
+			}
+			graf.Layout = g.Layout()
+			if len(graf.Images) > 1 {
+				graf.Layout = schema.GRID
+			}
+			if c := g.FirstChildElement("figcaption"); c != nil {
+				graf.Text = c.Text()
+				graf.Markups = c.Markup(report)
+			}
 		case g.HasClass("graf--mixtapeEmbed"):
 			graf.Type = schema.EMBED
 			graf.Text = g.Text()
-			// TODO(anton): Better support for mixtapes
+			graf.Embed = g.ExtractMixtape(report)
 		case g.HasClass("graf--pre"):
 			graf.Type = schema.PRE
 			graf.Text = g.TextPreformatted()
 		case g.HasClass("graf--empty"):
 			// Ignore empty grafs
 		default:
-			fmt.Printf("Unknown graf type: %s\n", g.Attrs["class"])
+			report.warn(schema.UNKNOWN_GRAF, g, "unknown graf type: %s", g.Attrs["class"])
 		}
 
 		if graf.Type != "" {
@@ -374,6 +704,45 @@ func (n *Node) ParseGrafs() []schema.Graf {
 	return grafs
 }
 
+// parseList parses an ol or ul element into a list graf. Medium doesn't
+// name lists so they take the name of their first item.
+func (n *Node) parseList(report Reporter) schema.Graf {
+	list := schema.Graf{
+		Type:    schema.UL,
+		Markups: []schema.Markup{},
+		Items:   []schema.Graf{},
+	}
+
+	if n.IsElement("ol") {
+		list.Type = schema.OL
+	}
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+
+		if !li.IsElement("li") || !li.HasClass("graf--li") {
+			report.forGraf(li.Attrs["name"]).warn(schema.UNKNOWN_GRAF, li, "unknown list item: %s", li.Attrs["class"])
+			continue
+		}
+
+		item := schema.Graf{
+			Type: schema.LI,
+			Name: li.Attrs["name"],
+			Text: li.Text(),
+		}
+		item.Markups = li.Markup(report.forGraf(item.Name))
+		list.Items = append(list.Items, item)
+	}
+
+	if len(list.Items) > 0 {
+		list.Name = list.Items[0].Name
+	}
+
+	return list
+}
+
 // IsElement returns true if the Node is html.ElementNode with a given tag name
 func (n *Node) IsElement(name string) bool {
 	return n.Type == html.ElementNode && n.Data == name
@@ -417,34 +786,54 @@ func (n *Node) HasClass(name string) bool {
 	return false
 }
 
-// UnzipArchive decompresses Medium Archive into directory dest
-func UnzipArchive(src string, dest string) (err error) {
-	r, err := zip.OpenReader(src)
-	if err != nil {
-		return
+// Limits applied to zip archives before anything is read from them. Real
+// Medium archives are nowhere near these numbers.
+var (
+	MaxArchiveEntries          = 50000
+	MaxArchiveBytes     uint64 = 2 << 30
+	MaxCompressionRatio uint64 = 200
+)
+
+// UnsafeArchiveError is returned when a zip archive contains entries that
+// could escape the destination directory or exceed archive limits.
+type UnsafeArchiveError struct {
+	Name   string
+	Reason string
+}
+
+func (e *UnsafeArchiveError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("meh: unsafe archive: %s", e.Reason)
+	}
+	return fmt.Sprintf("meh: unsafe archive entry %q: %s", e.Name, e.Reason)
+}
+
+// CheckArchive makes sure that a zip archive is safe to read or extract:
+// no absolute or parent paths, no symlinks, and entry count, total size
+// and compression ratio within limits.
+func CheckArchive(r *zip.Reader) error {
+	if len(r.File) > MaxArchiveEntries {
+		return &UnsafeArchiveError{Reason: fmt.Sprintf("more than %d entries", MaxArchiveEntries)}
 	}
-	defer r.Close()
 
+	var total uint64
 	for _, f := range r.File {
-		rc, err := f.Open()
-		if err != nil {
-			return err
+		name := strings.TrimSuffix(strings.ReplaceAll(f.Name, `\`, "/"), "/")
+		if name != "" && !fs.ValidPath(name) {
+			return &UnsafeArchiveError{Name: f.Name, Reason: "path escapes archive root"}
 		}
-		defer rc.Close()
 
-		if !f.FileInfo().IsDir() {
-			p := filepath.Join(dest, f.Name)
-			os.MkdirAll(filepath.Dir(p), 0700)
-			f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				continue
-			}
-			defer f.Close()
+		if f.Mode()&os.ModeSymlink != 0 {
+			return &UnsafeArchiveError{Name: f.Name, Reason: "symlinks are not allowed"}
+		}
 
-			_, err = io.Copy(f, rc)
-			if err != nil {
-				return err
-			}
+		total += f.UncompressedSize64
+		if total > MaxArchiveBytes {
+			return &UnsafeArchiveError{Reason: fmt.Sprintf("more than %d bytes uncompressed", MaxArchiveBytes)}
+		}
+
+		if f.UncompressedSize64/MaxCompressionRatio > f.CompressedSize64 && f.UncompressedSize64 > 1<<20 {
+			return &UnsafeArchiveError{Name: f.Name, Reason: "compression ratio is too high"}
 		}
 	}
 
@@ -496,40 +885,30 @@ func addToArchive(w *zip.Writer, src string, rel string) error {
 }
 
 // FindArchiveRoot attempts to find where the actual archive starts
-// by looking for a README.html file. It only looks max one level deep.
-func FindArchiveRoot(dir string) (string, error) {
+// by looking for a README.html file. It only looks max one level deep
+// and returns a file system rooted at the archive.
+func FindArchiveRoot(fsys fs.FS) (fs.FS, error) {
 	hasReadme := func(d string) bool {
-		files, err := ioutil.ReadDir(d)
-		if err != nil {
-			return false
-		}
-
-		for _, f := range files {
-			if f.Name() == "README.html" {
-				return true
-			}
-		}
-
-		return false
+		_, err := fs.Stat(fsys, path.Join(d, "README.html"))
+		return err == nil
 	}
 
-	if hasReadme(dir) {
-		return dir, nil
+	if hasReadme(".") {
+		return fsys, nil
 	}
 
-	files, err := ioutil.ReadDir(dir)
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	for _, d := range files {
-		fp := filepath.Join(dir, d.Name())
-		if d.IsDir() && hasReadme(fp) {
-			return fp, nil
+		if d.IsDir() && hasReadme(d.Name()) {
+			return fs.Sub(fsys, d.Name())
 		}
 	}
 
-	return "", ErrArchiveRootNotFound
+	return nil, ErrArchiveRootNotFound
 }
 
 // GenerateReceiptNumber returns a pseudo-random string of letters and digits.
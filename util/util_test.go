@@ -1,9 +1,15 @@
 package util_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/valueof/meh/schema"
 	"github.com/valueof/meh/util"
@@ -35,6 +41,42 @@ func TestParseMediumId(t *testing.T) {
 	}
 }
 
+func TestParseMediumSlug(t *testing.T) {
+	tests := map[string]string{
+		"https://anton.medium.com/birding-report-july-4th-7e904c599273":              "birding-report-july-4th",
+		"https://medium.com/programming-is-a-nightmare/heaven-and-hell-cb1ec71a9d4a": "heaven-and-hell",
+		"https://medium.com/@anton":         "",
+		"https://medium.com/p/c3b588867899": "",
+	}
+
+	for url, want := range tests {
+		have := util.ParseMediumSlug(url)
+		if want != have {
+			t.Errorf("url: %s; want: %s; have: %s", url, want, have)
+		}
+	}
+}
+
+func TestMediumFeedUrl(t *testing.T) {
+	tests := map[string]string{
+		"https://medium.com/@anton":            "https://medium.com/feed/@anton",
+		"https://medium.com/wildlife-trekker":  "https://medium.com/feed/wildlife-trekker",
+		"https://medium.com/tag/birds":         "https://medium.com/feed/tag/birds",
+		"https://medium.com/topic/photography": "https://medium.com/feed/tag/photography",
+		"https://blog.medium.com":              "https://blog.medium.com/feed",
+		"https://anton.medium.com/":            "https://anton.medium.com/feed",
+		"https://medium.com":                   "",
+		"not a url":                            "",
+	}
+
+	for url, want := range tests {
+		have := util.MediumFeedUrl(url)
+		if want != have {
+			t.Errorf("url: %s; want: %s; have: %s", url, want, have)
+		}
+	}
+}
+
 func TestParseMediumUsername(t *testing.T) {
 	tests := map[string]string{
 		"https://anton.medium.com/":      "anton",
@@ -51,6 +93,27 @@ func TestParseMediumUsername(t *testing.T) {
 	}
 }
 
+func TestParseEmbedSource(t *testing.T) {
+	tests := map[string]struct {
+		provider schema.EmbedProvider
+		source   string
+	}{
+		"https://www.youtube.com/embed/ifFUn9mAgVQ?feature=oembed":    {schema.YOUTUBE, "https://www.youtube.com/watch?v=ifFUn9mAgVQ"},
+		"https://youtu.be/ifFUn9mAgVQ":                                {schema.YOUTUBE, "https://www.youtube.com/watch?v=ifFUn9mAgVQ"},
+		"https://gist.github.com/anton/8d2c1e8f.js":                   {schema.GIST, "https://gist.github.com/anton/8d2c1e8f"},
+		"https://platform.twitter.com/embed/Tweet.html?id=1511336451": {schema.TWITTER, "https://twitter.com/i/status/1511336451"},
+		"https://codepen.io/anton/embed/preview/qBYxNaw?height=600":   {schema.CODEPEN, "https://codepen.io/anton/pen/qBYxNaw"},
+		"https://medium.com/@anton/oh-right-70c5683f3778":             {schema.LINK, "https://medium.com/@anton/oh-right-70c5683f3778"},
+	}
+
+	for src, want := range tests {
+		provider, source := util.ParseEmbedSource(src)
+		if provider != want.provider || source != want.source {
+			t.Errorf("src: %s; want: %s %s; have: %s %s", src, want.provider, want.source, provider, source)
+		}
+	}
+}
+
 func TestText(t *testing.T) {
 	tests := map[string]string{
 		`<p>The <em>owls</em> are not what <strong><em>they seem</em></strong></p>`: "The owls are not what they seem",
@@ -169,7 +232,7 @@ func TestMarkup(t *testing.T) {
 			continue
 		}
 
-		have := firstChild(node, "p").Markup()
+		have := firstChild(node, "p").Markup(nil)
 		if reflect.DeepEqual(have, tt.want) == false {
 			t.Errorf("test %d failed", n)
 			t.Errorf("want: %v; have: %v", tt.want, have)
@@ -177,6 +240,61 @@ func TestMarkup(t *testing.T) {
 	}
 }
 
+func TestUnsafeImageNames(t *testing.T) {
+	input := `<div>
+		<figure name="a1" class="graf graf--figure"><img class="graf-image" data-image-id="../../evil.png" src="https://cdn-images-1.medium.com/max/800/1*owl.jpeg"></figure>
+		<figure name="a2" class="graf graf--figure"><img class="graf-image" data-image-id="1*safe-owl.jpeg" src="https://cdn-images-1.medium.com/max/800/1*safe-owl.jpeg"></figure>
+		<div name="a3" class="graf graf--mixtapeEmbed"><a href="https://medium.com/@anton/oh-right-70c5683f3778"><strong>oh, right</strong></a><a href="https://medium.com/@anton/oh-right-70c5683f3778" class="js-mixtapeImage" data-thumbnail-img-id="../thumb.jpeg"></a></div>
+	</div>`
+
+	node, err := util.NewNodeFromHTML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("can't parse html: %v", err)
+	}
+
+	diagnostics := []schema.Diagnostic{}
+	grafs := firstChild(node, "div").ParseGrafs(func(d schema.Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	})
+
+	if len(grafs) != 3 {
+		t.Fatalf("want 3 grafs; have %d", len(grafs))
+	}
+
+	if img := grafs[0].Image; img == nil || img.Name != "" || img.Source == "" {
+		t.Errorf("want image without a name but with a source; have %+v", img)
+	}
+
+	if grafs[2].Embed == nil || grafs[2].Embed.Thumbnail != nil {
+		t.Errorf("want embed without a thumbnail; have %+v", grafs[2].Embed)
+	}
+
+	queued := map[string]bool{}
+	for _, name := range util.GetQueuedImages() {
+		queued[name] = true
+	}
+
+	if !queued["1*safe-owl.jpeg"] {
+		t.Errorf("want 1*safe-owl.jpeg to be queued")
+	}
+
+	for _, name := range []string{"../../evil.png", "../thumb.jpeg"} {
+		if queued[name] {
+			t.Errorf("want %s not to be queued", name)
+		}
+	}
+
+	if len(diagnostics) != 2 {
+		t.Fatalf("want 2 diagnostics; have %v", diagnostics)
+	}
+
+	for _, d := range diagnostics {
+		if d.Code != schema.UNSAFE_IMAGE {
+			t.Errorf("want %s; have %s", schema.UNSAFE_IMAGE, d.Code)
+		}
+	}
+}
+
 func TestHasClass(t *testing.T) {
 	tests := map[string]bool{
 		`<p class="graf">graf</p>`:       true,
@@ -194,3 +312,92 @@ func TestHasClass(t *testing.T) {
 		}
 	}
 }
+
+func TestFindArchiveRoot(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		".": {
+			"README.html":      {},
+			"claps/claps.html": {},
+		},
+		"medium-export": {
+			"medium-export/README.html":      {},
+			"medium-export/claps/claps.html": {},
+		},
+	}
+
+	for name, fsys := range tests {
+		root, err := util.FindArchiveRoot(fsys)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if _, err := fs.Stat(root, "claps/claps.html"); err != nil {
+			t.Errorf("%s: archive root is wrong: %v", name, err)
+		}
+	}
+
+	_, err := util.FindArchiveRoot(fstest.MapFS{"a/b/README.html": {}})
+	if err != util.ErrArchiveRootNotFound {
+		t.Errorf("want ErrArchiveRootNotFound; have %v", err)
+	}
+}
+
+func newZip(t *testing.T, files map[string]string, setup func(*zip.FileHeader)) *bytes.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, body := range files {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		if setup != nil {
+			setup(h)
+		}
+
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatalf("can't create %s: %v", name, err)
+		}
+		f.Write([]byte(body))
+	}
+	w.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestCheckArchive(t *testing.T) {
+	tests := map[string]*bytes.Reader{
+		"parent":   newZip(t, map[string]string{"../evil.html": "x"}, nil),
+		"absolute": newZip(t, map[string]string{"/etc/evil.html": "x"}, nil),
+		"windows":  newZip(t, map[string]string{`..\evil.html`: "x"}, nil),
+		"symlink": newZip(t, map[string]string{"README.html": "/etc/passwd"}, func(h *zip.FileHeader) {
+			h.SetMode(os.ModeSymlink | 0777)
+		}),
+		"ratio": newZip(t, map[string]string{"README.html": strings.Repeat("a", 4<<20)}, nil),
+	}
+
+	for name, zr := range tests {
+		r, err := zip.NewReader(zr, zr.Size())
+		if err != nil {
+			t.Fatalf("%s: can't read zip: %v", name, err)
+		}
+
+		var uae *util.UnsafeArchiveError
+		err = util.CheckArchive(r)
+		if !errors.As(err, &uae) {
+			t.Errorf("%s: want UnsafeArchiveError; have %v", name, err)
+		}
+	}
+
+	zr := newZip(t, map[string]string{"medium-export/README.html": "ok", "medium-export/posts/": ""}, nil)
+	r, _ := zip.NewReader(zr, zr.Size())
+	if err := util.CheckArchive(r); err != nil {
+		t.Errorf("want no error for a valid archive; have %v", err)
+	}
+
+	max := util.MaxArchiveEntries
+	util.MaxArchiveEntries = 1
+	defer func() { util.MaxArchiveEntries = max }()
+
+	var uae *util.UnsafeArchiveError
+	if err := util.CheckArchive(r); !errors.As(err, &uae) {
+		t.Errorf("want UnsafeArchiveError for too many entries; have %v", err)
+	}
+}